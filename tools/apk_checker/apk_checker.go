@@ -0,0 +1,389 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apk_checker verifies the APKs staged into the suite before a release, and
+// reports the ones that are unsigned, corrupt, not zip-aligned or that have
+// inconsistent version codes.
+//
+// Usage:
+//
+//	apk_checker [-aapt2 <path>] [-skip_versions] [-json] <apk dir or file>...
+//
+// Directories are scanned recursively for .apk files and .apks APK sets;
+// every APK inside a set is checked. An APK is unsigned when it has neither
+// a complete v1 (JAR) signature nor an APK Signing Block (v2 and later). It
+// is corrupt when it is not a valid zip archive or an entry fails its CRC
+// check. Stored entries must be 4-byte aligned, and stored native libraries
+// page aligned, as zipalign -p does. Version codes are read with aapt2: each
+// APK must have a positive version code, and all APKs of a package, e.g. a
+// base APK and its splits, must have the same one. apk_checker exits with
+// status 1 when any problem is reported.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Checks reported by apk_checker.
+const (
+	checkCorrupt   = "corrupt"
+	checkUnsigned  = "unsigned"
+	checkAlignment = "alignment"
+	checkVersion   = "version"
+)
+
+const (
+	alignment      = 4
+	soAlignment    = 4096
+	sigBlockMagic  = "APK Sig Block 42"
+	eocdSignature  = 0x06054b50
+	eocdSize       = 22
+	maxCommentSize = 0xffff
+)
+
+type problem struct {
+	// Path is the APK path; APKs in a set are named <set>!<entry>.
+	Path   string `json:"path"`
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// apkFile is an APK to check. file is where it is on disk, which is a
+// temporary file for APKs extracted from a set.
+type apkFile struct {
+	name string
+	file string
+}
+
+// findAPKs returns the APKs in paths, extracting APK sets into tmp.
+func findAPKs(paths []string, tmp string) ([]apkFile, []problem, error) {
+	var apks []apkFile
+	var problems []problem
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			switch filepath.Ext(p) {
+			case ".apk":
+				apks = append(apks, apkFile{name: p, file: p})
+			case ".apks":
+				set, err := extractSet(p, filepath.Join(tmp, strconv.Itoa(len(apks))))
+				if err != nil {
+					problems = append(problems, problem{Path: p, Check: checkCorrupt, Detail: err.Error()})
+					return nil
+				}
+				apks = append(apks, set...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return apks, problems, nil
+}
+
+// extractSet extracts the APKs of an APK set into dir.
+func extractSet(set, dir string) ([]apkFile, error) {
+	r, err := zip.OpenReader(set)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var apks []apkFile
+	for i, f := range r.File {
+		if path.Ext(f.Name) != ".apk" {
+			continue
+		}
+		file := filepath.Join(dir, strconv.Itoa(i)+".apk")
+		if err := extractFile(f, file); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		apks = append(apks, apkFile{name: set + "!" + f.Name, file: file})
+	}
+	if len(apks) == 0 {
+		return nil, fmt.Errorf("no APKs in set")
+	}
+	return apks, nil
+}
+
+func extractFile(f *zip.File, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checkArchive checks the integrity, signature and alignment of an APK.
+func checkArchive(a apkFile) []problem {
+	report := func(check, format string, args ...interface{}) []problem {
+		return []problem{{Path: a.name, Check: check, Detail: fmt.Sprintf(format, args...)}}
+	}
+	f, err := os.Open(a.file)
+	if err != nil {
+		return report(checkCorrupt, "%v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return report(checkCorrupt, "%v", err)
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return report(checkCorrupt, "%v", err)
+	}
+
+	var problems []problem
+	for _, e := range r.File {
+		if err := verifyEntry(e); err != nil {
+			return report(checkCorrupt, "%s: %v", e.Name, err)
+		}
+		if e.Method != zip.Store {
+			continue
+		}
+		offset, err := e.DataOffset()
+		if err != nil {
+			return report(checkCorrupt, "%s: %v", e.Name, err)
+		}
+		want := int64(alignment)
+		if path.Ext(e.Name) == ".so" {
+			want = soAlignment
+		}
+		if offset%want != 0 {
+			problems = append(problems, report(checkAlignment,
+				"%s is stored at offset %d, not aligned to %d bytes", e.Name, offset, want)...)
+		}
+	}
+
+	v2, err := hasSigningBlock(f, info.Size())
+	if err != nil {
+		return report(checkCorrupt, "%v", err)
+	}
+	if !v2 && !hasV1Signature(r) {
+		problems = append(problems, report(checkUnsigned, "no v1 signature or APK Signing Block")...)
+	}
+	return problems
+}
+
+// verifyEntry reads an entry fully, which checks its CRC.
+func verifyEntry(e *zip.File) error {
+	rc, err := e.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// hasV1Signature reports whether the archive has a manifest, a signature
+// file and a signature block, as a JAR-signed APK does.
+func hasV1Signature(r *zip.Reader) bool {
+	var manifest, sf, block bool
+	for _, e := range r.File {
+		dir, name := path.Split(e.Name)
+		if dir != "META-INF/" {
+			continue
+		}
+		switch ext := strings.ToUpper(path.Ext(name)); {
+		case name == "MANIFEST.MF":
+			manifest = true
+		case ext == ".SF":
+			sf = true
+		case ext == ".RSA" || ext == ".DSA" || ext == ".EC":
+			block = true
+		}
+	}
+	return manifest && sf && block
+}
+
+// hasSigningBlock reports whether an APK Signing Block, which holds v2 and
+// later signatures, precedes the zip central directory.
+func hasSigningBlock(r io.ReaderAt, size int64) (bool, error) {
+	tail := int64(eocdSize + maxCommentSize)
+	if tail > size {
+		tail = size
+	}
+	buf := make([]byte, tail)
+	if _, err := r.ReadAt(buf, size-tail); err != nil && err != io.EOF {
+		return false, err
+	}
+	eocd := -1
+	for i := len(buf) - eocdSize; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == eocdSignature {
+			eocd = i
+			break
+		}
+	}
+	if eocd < 0 {
+		return false, fmt.Errorf("no end of central directory record")
+	}
+	cdOffset := int64(binary.LittleEndian.Uint32(buf[eocd+16:]))
+	if cdOffset < int64(len(sigBlockMagic)) || cdOffset > size {
+		return false, nil
+	}
+	magic := make([]byte, len(sigBlockMagic))
+	if _, err := r.ReadAt(magic, cdOffset-int64(len(magic))); err != nil {
+		return false, err
+	}
+	return bytes.Equal(magic, []byte(sigBlockMagic)), nil
+}
+
+var packagePattern = regexp.MustCompile(`^package: .*?\bname='([^']*)'.*?\bversionCode='([^']*)'`)
+
+// runBadging runs aapt2 dump badging on path. It is a variable so that
+// tests can run without aapt2.
+var runBadging = func(aapt2, path string) ([]byte, error) {
+	out, err := exec.Command(aapt2, "dump", "badging", path).Output()
+	if e, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("%s dump badging failed: %v: %s", aapt2, err, strings.TrimSpace(string(e.Stderr)))
+	}
+	return out, err
+}
+
+// checkVersions checks that every APK has a positive version code, and
+// that the APKs of each package agree on it.
+func checkVersions(aapt2 string, apks []apkFile) ([]problem, error) {
+	var problems []problem
+	versions := make(map[string]map[string][]string)
+	for _, a := range apks {
+		out, err := runBadging(aapt2, a.file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", a.name, err)
+		}
+		m := packagePattern.FindSubmatch(out)
+		if m == nil {
+			problems = append(problems, problem{Path: a.name, Check: checkVersion, Detail: "no package name or version code"})
+			continue
+		}
+		pkg, code := string(m[1]), string(m[2])
+		if n, err := strconv.ParseInt(code, 10, 64); err != nil || n <= 0 {
+			problems = append(problems, problem{Path: a.name, Check: checkVersion, Detail: fmt.Sprintf("invalid version code %q", code)})
+			continue
+		}
+		if versions[pkg] == nil {
+			versions[pkg] = make(map[string][]string)
+		}
+		versions[pkg][code] = append(versions[pkg][code], a.name)
+	}
+	for pkg, codes := range versions {
+		if len(codes) < 2 {
+			continue
+		}
+		var desc []string
+		for code, names := range codes {
+			desc = append(desc, fmt.Sprintf("%s in %s", code, strings.Join(names, ", ")))
+		}
+		sort.Strings(desc)
+		problems = append(problems, problem{Path: pkg, Check: checkVersion,
+			Detail: "conflicting version codes " + strings.Join(desc, "; ")})
+	}
+	return problems, nil
+}
+
+func check(paths []string, aapt2 string, versions bool) ([]problem, error) {
+	tmp, err := os.MkdirTemp("", "apk_checker")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	apks, problems, err := findAPKs(paths, tmp)
+	if err != nil {
+		return nil, err
+	}
+	var intact []apkFile
+	for _, a := range apks {
+		p := checkArchive(a)
+		problems = append(problems, p...)
+		if len(p) == 0 || p[0].Check != checkCorrupt {
+			intact = append(intact, a)
+		}
+	}
+	if versions {
+		p, err := checkVersions(aapt2, intact)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, p...)
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems, nil
+}
+
+func main() {
+	aapt2 := flag.String("aapt2", "aapt2", "path to aapt2")
+	skipVersions := flag.Bool("skip_versions", false, "do not check version codes, so that aapt2 is not needed")
+	asJSON := flag.Bool("json", false, "write the problems as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-aapt2 <path>] [-skip_versions] [-json] <apk dir or file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	problems, err := check(flag.Args(), *aapt2, !*skipVersions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if problems == nil {
+			problems = []problem{}
+		}
+		e.Encode(problems)
+	} else {
+		for _, p := range problems {
+			fmt.Printf("%s: %s: %s\n", p.Path, p.Check, p.Detail)
+		}
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type entry struct {
+	name    string
+	content string
+	stored  bool
+	// padding is the length of the extra field, used to align stored data.
+	padding int
+}
+
+// makeZip returns a zip archive of entries. When sigBlock is set, an APK
+// Signing Block magic is inserted before the central directory.
+func makeZip(t *testing.T, sigBlock bool, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Extra: make([]byte, e.padding)}
+		if e.stored {
+			h.Method = zip.Store
+		}
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !sigBlock {
+		return data
+	}
+	cd := binary.LittleEndian.Uint32(data[len(data)-eocdSize+16:])
+	out := append(append(append([]byte{}, data[:cd]...), sigBlockMagic...), data[cd:]...)
+	binary.LittleEndian.PutUint32(out[len(out)-eocdSize+16:], cd+uint32(len(sigBlockMagic)))
+	return out
+}
+
+func badging(pkg, code string) entry {
+	return entry{name: "badging.txt", content: "package: name='" + pkg + "' versionCode='" + code + "' versionName='1.0'\n"}
+}
+
+var v1Signature = []entry{
+	{name: "META-INF/MANIFEST.MF", content: "Manifest-Version: 1.0\n"},
+	{name: "META-INF/CERT.SF", content: "Signature-Version: 1.0\n"},
+	{name: "META-INF/CERT.RSA", content: "block"},
+}
+
+func writeFile(t *testing.T, p string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeBadging reads the badging output stored in the APK, so that tests
+// run without aapt2.
+func fakeBadging(aapt2, p string) ([]byte, error) {
+	r, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == "badging.txt" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			var b bytes.Buffer
+			_, err = b.ReadFrom(rc)
+			return b.Bytes(), err
+		}
+	}
+	return nil, nil
+}
+
+func TestHasSigningBlock(t *testing.T) {
+	for _, sigBlock := range []bool{false, true} {
+		data := makeZip(t, sigBlock, entry{name: "classes.dex", content: "dex"})
+
+		got, err := hasSigningBlock(bytes.NewReader(data), int64(len(data)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != sigBlock {
+			t.Errorf("got %v, want %v", got, sigBlock)
+		}
+	}
+}
+
+func TestHasV1Signature(t *testing.T) {
+	for _, tc := range []struct {
+		entries []entry
+		want    bool
+	}{
+		{v1Signature, true},
+		{v1Signature[:2], false},
+		{[]entry{{name: "classes.dex"}}, false},
+	} {
+		data := makeZip(t, false, tc.entries...)
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := hasV1Signature(r); got != tc.want {
+			t.Errorf("hasV1Signature(%v) = %v, want %v", tc.entries, got, tc.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	runBadging = fakeBadging
+	dir := t.TempDir()
+	// Local file headers are 30 bytes followed by the name and extra field.
+	writeFile(t, filepath.Join(dir, "good", "base.apk"), makeZip(t, true,
+		badging("com.example.good", "10"),
+		entry{name: "res/raw.bin", content: "raw", stored: true, padding: 3}))
+	writeFile(t, filepath.Join(dir, "v1.apk"), makeZip(t, false,
+		append([]entry{badging("com.example.v1", "1")}, v1Signature...)...))
+	writeFile(t, filepath.Join(dir, "unsigned.apk"), makeZip(t, false,
+		badging("com.example.unsigned", "1")))
+	writeFile(t, filepath.Join(dir, "unaligned.apk"), makeZip(t, true,
+		badging("com.example.unaligned", "1"),
+		entry{name: "res/raw.bin", content: "raw", stored: true}))
+	writeFile(t, filepath.Join(dir, "corrupt.apk"), []byte("not a zip"))
+	writeFile(t, filepath.Join(dir, "app.apks"), makeZip(t, false,
+		entry{name: "splits/base.apk", content: string(makeZip(t, true, badging("com.example.set", "2")))},
+		entry{name: "splits/base-en.apk", content: string(makeZip(t, true, badging("com.example.set", "3")))},
+		entry{name: "toc.pb", content: "toc"}))
+	writeFile(t, filepath.Join(dir, "zero.apk"), makeZip(t, true, badging("com.example.zero", "0")))
+	writeFile(t, filepath.Join(dir, "notes.txt"), []byte("ignored"))
+
+	got, err := check([]string{dir}, "aapt2", true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []problem{
+		{Path: filepath.Join(dir, "corrupt.apk"), Check: checkCorrupt, Detail: "zip: not a valid zip file"},
+		{Path: filepath.Join(dir, "unaligned.apk"), Check: checkAlignment,
+			Detail: "res/raw.bin is stored at offset 177, not aligned to 4 bytes"},
+		{Path: filepath.Join(dir, "unsigned.apk"), Check: checkUnsigned, Detail: "no v1 signature or APK Signing Block"},
+		{Path: filepath.Join(dir, "zero.apk"), Check: checkVersion, Detail: `invalid version code "0"`},
+		{Path: "com.example.set", Check: checkVersion,
+			Detail: "conflicting version codes 2 in " + filepath.Join(dir, "app.apks") + "!splits/base.apk; 3 in " +
+				filepath.Join(dir, "app.apks") + "!splits/base-en.apk"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v,\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apk_metadata reads the package name, version and SDK levels of every APK
+// in a directory with aapt2, and writes them as a package list file for
+// generate_module.py, a ranking file for PublicApkUtil, or JSON.
+//
+// Usage:
+//
+//	apk_metadata [-aapt2 <path>] [-format list|ranking|json] [-o <file>] <apk dir>
+//
+// The directory is scanned recursively, so both a flat directory of APKs and
+// the <package>/<apk> layout read by AppSetupPreparer work. Split APKs are
+// skipped; each package is reported once, from its base APK. Ranking files
+// have no rank information, so every rank is -1, as when PublicApkUtil
+// scans a directory itself.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+)
+
+type apk struct {
+	Package     string `json:"package"`
+	VersionCode string `json:"version_code"`
+	VersionName string `json:"version_name"`
+	MinSdk      string `json:"min_sdk,omitempty"`
+	TargetSdk   string `json:"target_sdk,omitempty"`
+	// Split is the split name of a split APK.
+	Split string `json:"-"`
+	// Path is relative to the scanned directory.
+	Path string `json:"path"`
+}
+
+var (
+	attrPattern       = regexp.MustCompile(`(\w+)='([^']*)'`)
+	sdkLinePattern    = regexp.MustCompile(`^(sdkVersion|minSdkVersion|targetSdkVersion):'([^']*)'`)
+	packageLinePrefix = "package: "
+)
+
+// parseBadging reads the output of aapt2 dump badging.
+func parseBadging(r io.Reader) (apk, error) {
+	var a apk
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, packageLinePrefix) {
+			for _, m := range attrPattern.FindAllStringSubmatch(line, -1) {
+				switch m[1] {
+				case "name":
+					a.Package = m[2]
+				case "versionCode":
+					a.VersionCode = m[2]
+				case "versionName":
+					a.VersionName = m[2]
+				case "split":
+					a.Split = m[2]
+				}
+			}
+			continue
+		}
+		if m := sdkLinePattern.FindStringSubmatch(line); m != nil {
+			if m[1] == "targetSdkVersion" {
+				a.TargetSdk = m[2]
+			} else {
+				a.MinSdk = m[2]
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return a, err
+	}
+	if a.Package == "" {
+		return a, fmt.Errorf("no package line in badging output")
+	}
+	return a, nil
+}
+
+// runBadging runs aapt2 dump badging on path. It is a variable so that
+// tests can run without aapt2.
+var runBadging = func(aapt2, path string) ([]byte, error) {
+	out, err := exec.Command(aapt2, "dump", "badging", path).Output()
+	if e, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("%s dump badging failed: %v: %s", aapt2, err, strings.TrimSpace(string(e.Stderr)))
+	}
+	return out, err
+}
+
+// scan returns the base APKs under dir, sorted by package name.
+func scan(aapt2, dir string) ([]apk, error) {
+	var apks []apk
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(p) != ".apk" {
+			return err
+		}
+		out, err := runBadging(aapt2, p)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		a, err := parseBadging(strings.NewReader(string(out)))
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		if a.Split != "" || seen[a.Package] {
+			return nil
+		}
+		if !packagelist.ValidPackageName(a.Package) {
+			return fmt.Errorf("%s: invalid package name %q", p, a.Package)
+		}
+		seen[a.Package] = true
+		if a.Path, err = filepath.Rel(dir, p); err != nil {
+			return err
+		}
+		apks = append(apks, a)
+		return nil
+	})
+	sort.Slice(apks, func(i, j int) bool { return apks[i].Package < apks[j].Package })
+	return apks, err
+}
+
+func write(w io.Writer, format string, apks []apk) error {
+	switch format {
+	case "list":
+		var names []string
+		for _, a := range apks {
+			names = append(names, a.Package)
+		}
+		return packagelist.Write(w, names)
+	case "ranking":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"rank", "package", "version_string", "version_code", "file_name"})
+		for _, a := range apks {
+			cw.Write([]string{"-1", a.Package, a.VersionName, a.VersionCode, filepath.ToSlash(a.Path)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		if apks == nil {
+			apks = []apk{}
+		}
+		return e.Encode(apks)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	aapt2 := flag.String("aapt2", "aapt2", "path to aapt2")
+	format := flag.String("format", "list", "output format: list, ranking or json")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-aapt2 <path>] [-format list|ranking|json] [-o <file>] <apk dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "list" && *format != "ranking" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	apks, err := scan(*aapt2, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var f *os.File
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		w = f
+	}
+	err = write(w, *format, apks)
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
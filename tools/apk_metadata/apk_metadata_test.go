@@ -0,0 +1,138 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const badging = `package: name='com.example.app' versionCode='42' versionName='1.2.3' platformBuildVersionName='11' compileSdkVersion='30'
+sdkVersion:'21'
+targetSdkVersion:'30'
+uses-permission: name='android.permission.INTERNET'
+application-label:'Example'
+launchable-activity: name='com.example.app.Main'  label='' icon=''
+`
+
+func TestParseBadging(t *testing.T) {
+	got, err := parseBadging(strings.NewReader(badging))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := apk{Package: "com.example.app", VersionCode: "42", VersionName: "1.2.3", MinSdk: "21", TargetSdk: "30"}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseBadging_noPackage_returnsError(t *testing.T) {
+	if _, err := parseBadging(strings.NewReader("ERROR: dump failed\n")); err == nil {
+		t.Error("parseBadging() succeeded, want error")
+	}
+}
+
+func TestScan_skipsSplitsAndDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	outputs := map[string]string{
+		"com.example.b/base.apk":            "package: name='com.example.b' versionCode='2' versionName='2.0'\n",
+		"com.example.b/split_config.en.apk": "package: name='com.example.b' versionCode='2' versionName='2.0' split='config.en'\n",
+		"a.apk":                             "package: name='com.example.a' versionCode='1' versionName='1.0'\n",
+		"a-copy.apk":                        "package: name='com.example.a' versionCode='1' versionName='1.0'\n",
+	}
+	for name := range outputs {
+		writeFile(t, filepath.Join(dir, name))
+	}
+	writeFile(t, filepath.Join(dir, "README"))
+	fakeBadging(t, func(path string) ([]byte, error) {
+		rel, _ := filepath.Rel(dir, path)
+		out, ok := outputs[filepath.ToSlash(rel)]
+		if !ok {
+			return nil, fmt.Errorf("unexpected file %s", rel)
+		}
+		return []byte(out), nil
+	})
+
+	got, err := scan("aapt2", dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []apk{
+		{Package: "com.example.a", VersionCode: "1", VersionName: "1.0", Path: "a-copy.apk"},
+		{Package: "com.example.b", VersionCode: "2", VersionName: "2.0", Path: filepath.Join("com.example.b", "base.apk")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestScan_aapt2Fails_returnsError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.apk"))
+	fakeBadging(t, func(string) ([]byte, error) { return nil, fmt.Errorf("not an APK") })
+
+	if _, err := scan("aapt2", dir); err == nil {
+		t.Error("scan() succeeded, want error")
+	}
+}
+
+func TestWrite_ranking(t *testing.T) {
+	var b bytes.Buffer
+	apks := []apk{{Package: "com.example.a", VersionCode: "1", VersionName: "1.0, beta", Path: "a.apk"}}
+
+	if err := write(&b, "ranking", apks); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "rank,package,version_string,version_code,file_name\n-1,com.example.a,\"1.0, beta\",1,a.apk\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestWrite_list(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := write(&b, "list", []apk{{Package: "com.example.a"}, {Package: "com.example.b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "com.example.a\ncom.example.b\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func fakeBadging(t *testing.T, f func(path string) ([]byte, error)) {
+	original := runBadging
+	runBadging = func(_, path string) ([]byte, error) { return f(path) }
+	t.Cleanup(func() { runBadging = original })
+}
+
+func writeFile(t *testing.T, p string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// app_list_generator converts app catalogs exported from spreadsheets or
+// other tools into a package list file for generate_module.py.
+//
+// Usage:
+//
+//	app_list_generator [-column <name>] [-strict] [-o <file>] <catalog>...
+//
+// Catalogs ending in .json hold an array of package names, or an array of
+// objects with the package name in the -column field. Other catalogs are CSV
+// files with a header row, with the package name in the -column column. When
+// -column is not set, the common names package, package_name, packageName
+// and app_package are tried, and CSV files with the rank, package, version
+// string, version code and file name columns read by PublicApkUtil are
+// recognized by their shape.
+//
+// Package names are deduplicated across catalogs, keeping the first
+// occurrence, and invalid names are reported and dropped. With -strict, any
+// invalid name fails the run.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+)
+
+func readCatalog(path string, r io.Reader, column string) ([]packagelist.Record, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return packagelist.ReadJSONCatalog(path, r, column)
+	}
+	return packagelist.ReadCSVCatalog(path, r, column)
+}
+
+// packageNames returns the valid package names of records without
+// duplicates, and a message for every invalid name.
+func packageNames(records []packagelist.Record) ([]string, []string) {
+	var names, invalid []string
+	for _, r := range records {
+		if r.Package == "" {
+			continue
+		}
+		if !packagelist.ValidPackageName(r.Package) {
+			invalid = append(invalid, fmt.Sprintf("%s: invalid package name %q", r.Location, r.Package))
+			continue
+		}
+		names = append(names, r.Package)
+	}
+	return packagelist.Dedup(names), invalid
+}
+
+func main() {
+	column := flag.String("column", "", "CSV column or JSON field holding the package names")
+	strict := flag.Bool("strict", false, "fail if any package name is invalid")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-column <name>] [-strict] [-o <file>] <catalog>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var records []packagelist.Record
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		catalog, err := readCatalog(path, f, *column)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		records = append(records, catalog...)
+	}
+
+	names, invalid := packageNames(records)
+	for _, message := range invalid {
+		fmt.Fprintln(os.Stderr, message)
+	}
+	if *strict && len(invalid) > 0 {
+		os.Exit(1)
+	}
+
+	var f *os.File
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		w = f
+	}
+	err := packagelist.Write(w, names)
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
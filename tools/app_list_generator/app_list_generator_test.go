@@ -0,0 +1,71 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+)
+
+func TestReadCatalog_csv_readsPackageColumn(t *testing.T) {
+	records, err := readCatalog("apps.csv", strings.NewReader("title,package_name\nApp A,com.example.a\n"), "")
+
+	if err != nil {
+		t.Fatalf("readCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a")
+}
+
+func TestReadCatalog_json_readsPackageField(t *testing.T) {
+	records, err := readCatalog("apps.JSON", strings.NewReader(`[{"pkg": "com.example.a"}]`), "pkg")
+
+	if err != nil {
+		t.Fatalf("readCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a")
+}
+
+func TestPackageNames_dedupsAndReportsInvalidNames(t *testing.T) {
+	records := []packagelist.Record{
+		{Location: "a.csv:2", Package: "com.example.a"},
+		{Location: "a.csv:3", Package: "not a package"},
+		{Location: "a.csv:4", Package: ""},
+		{Location: "b.json[0]", Package: "com.example.a"},
+		{Location: "b.json[1]", Package: "com.example.b"},
+	}
+
+	names, invalid := packageNames(records)
+
+	if want := []string{"com.example.a", "com.example.b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got names %v, want %v", names, want)
+	}
+	if len(invalid) != 1 || !strings.HasPrefix(invalid[0], "a.csv:3:") {
+		t.Errorf("got invalid %v, want one message for a.csv:3", invalid)
+	}
+}
+
+func assertNames(t *testing.T, records []packagelist.Record, names ...string) {
+	t.Helper()
+	var got []string
+	for _, r := range records {
+		got = append(got, r.Package)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("got names %v, want %v", got, names)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// bazel_converter translates the plans of a built C-Suite into Bazel test
+// targets that run csuite-tradefed, for labs that run tests through Bazel.
+//
+// Usage:
+//
+//	bazel_converter -runner <label> [-data <label>]... [-per_app=false] [-o <BUILD file>] <suite zip or dir> [<plan>...]
+//
+// The runner is an executable, such as a shell script wrapping
+// csuite-tradefed, that is passed the Tradefed command line, e.g. "run
+// commandAndExit launch". Data labels, typically the suite itself, are
+// added to every target. Unless plans are named, every plan that filters
+// modules on plan metadata is converted; other plans, like csuite-base, are
+// only included by the runnable ones.
+//
+// By default, each app a plan selects gets an sh_test named
+// <plan>_<package> that runs the plan with an include filter on the app's
+// module, and the plan is a test_suite of those targets. The apps of a plan
+// are the modules whose plan metadata matches one of the plan's module
+// metadata filters. With -per_app=false, each plan is a single sh_test.
+// Targets are tagged exclusive, since they share a device, and external.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+const (
+	generator = "test/app_compat/csuite/tools/bazel_converter"
+)
+
+var tags = []string{"exclusive", "external"}
+
+type target struct {
+	name string
+	args []string
+}
+
+type converter struct {
+	suite  *suite.Suite
+	runner string
+	data   []string
+	perApp bool
+}
+
+// targetName returns the name of the target running module in plan.
+func targetName(plan, module string) string {
+	if pkg, ok := packagelist.PackageName(module); ok {
+		return plan + "_" + pkg
+	}
+	return plan + "_" + module
+}
+
+func (c *converter) write(w io.Writer, plans []string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# This file was auto-generated by %s.\n# Do not edit manually.\n", generator)
+	for _, plan := range plans {
+		command := []string{"run", "commandAndExit", plan}
+		if !c.perApp {
+			c.writeTest(&b, target{name: plan, args: command})
+			continue
+		}
+		modules, err := c.suite.PlanModules(plan)
+		if err != nil {
+			return err
+		}
+		if len(modules) == 0 {
+			return fmt.Errorf("plan %s selects no modules; use -per_app=false", plan)
+		}
+		var names []string
+		for _, m := range modules {
+			names = append(names, ":"+targetName(plan, m.Name))
+		}
+		b.WriteString("\ntest_suite(\n")
+		fmt.Fprintf(&b, "    name = %s,\n", quote(plan))
+		writeList(&b, "tests", names)
+		fmt.Fprintf(&b, "    tags = [%s],\n", quoteAll(tags))
+		b.WriteString(")\n")
+		for _, m := range modules {
+			args := append(append([]string(nil), command...), "--include-filter", m.Name)
+			c.writeTest(&b, target{name: targetName(plan, m.Name), args: args})
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (c *converter) writeTest(b *bytes.Buffer, t target) {
+	b.WriteString("\nsh_test(\n")
+	fmt.Fprintf(b, "    name = %s,\n", quote(t.name))
+	fmt.Fprintf(b, "    srcs = [%s],\n", quote(c.runner))
+	writeList(b, "args", t.args)
+	if len(c.data) > 0 {
+		writeList(b, "data", c.data)
+	}
+	fmt.Fprintf(b, "    tags = [%s],\n", quoteAll(tags))
+	b.WriteString(")\n")
+}
+
+func writeList(b *bytes.Buffer, attr string, values []string) {
+	fmt.Fprintf(b, "    %s = [\n", attr)
+	for _, v := range values {
+		fmt.Fprintf(b, "        %s,\n", quote(v))
+	}
+	b.WriteString("    ],\n")
+}
+
+// quote returns s as a Starlark string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func quoteAll(values []string) string {
+	var quoted []string
+	for _, v := range values {
+		quoted = append(quoted, quote(v))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func main() {
+	var data flagutil.StringList
+	c := &converter{}
+	flag.StringVar(&c.runner, "runner", "", "label of the executable that runs csuite-tradefed")
+	flag.Var(&data, "data", "label of data the targets need, e.g. the suite; may be repeated")
+	flag.BoolVar(&c.perApp, "per_app", true, "generate a target per app instead of per plan")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s -runner <label> [-data <label>]... [-per_app=false] [-o <BUILD file>] <suite zip or dir> [<plan>...]\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || c.runner == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c.data = data
+
+	s, err := suite.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	c.suite = s
+	plans := flag.Args()[1:]
+	if len(plans) == 0 {
+		for _, p := range s.Plans() {
+			filters, err := s.PlanMetadataFilters(p.Name)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			if len(filters) > 0 {
+				plans = append(plans, p.Name)
+			}
+		}
+	}
+	for _, p := range plans {
+		if _, ok := s.Configs[p]; !ok {
+			fmt.Fprintf(os.Stderr, "plan %q not found\n", p)
+			os.Exit(2)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := c.write(&b, plans); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		os.Stdout.Write(b.Bytes())
+		return
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+func newSuite() *suite.Suite {
+	s := suite.New()
+	s.Configs["launch"] = &suite.Config{Name: "launch", Plan: true, Data: []byte(`<configuration>
+  <include name="csuite-base" />
+  <option name="compatibility:module-metadata-include-filter" key="plan" value="app-launch" />
+</configuration>`)}
+	s.Configs["csuite-base"] = &suite.Config{Name: "csuite-base", Plan: true, Data: []byte(`<configuration />`)}
+	for name, plan := range map[string]string{
+		"csuite_com.example.b": "app-launch",
+		"csuite_com.example.a": "app-launch",
+		"csuite_com.example.c": "app-crawl",
+	} {
+		s.Modules[name] = &suite.Config{Name: name, Data: []byte(
+			`<configuration><option name="config-descriptor:metadata" key="plan" value="` + plan + `" /></configuration>`)}
+	}
+	return s
+}
+
+func TestWrite_perApp(t *testing.T) {
+	c := &converter{suite: newSuite(), runner: "//tools:run_csuite.sh", data: []string{"//:android-csuite"}, perApp: true}
+	var b strings.Builder
+
+	if err := c.write(&b, []string{"launch"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# This file was auto-generated by test/app_compat/csuite/tools/bazel_converter.
+# Do not edit manually.
+
+test_suite(
+    name = "launch",
+    tests = [
+        ":launch_com.example.a",
+        ":launch_com.example.b",
+    ],
+    tags = ["exclusive", "external"],
+)
+
+sh_test(
+    name = "launch_com.example.a",
+    srcs = ["//tools:run_csuite.sh"],
+    args = [
+        "run",
+        "commandAndExit",
+        "launch",
+        "--include-filter",
+        "csuite_com.example.a",
+    ],
+    data = [
+        "//:android-csuite",
+    ],
+    tags = ["exclusive", "external"],
+)
+
+sh_test(
+    name = "launch_com.example.b",
+    srcs = ["//tools:run_csuite.sh"],
+    args = [
+        "run",
+        "commandAndExit",
+        "launch",
+        "--include-filter",
+        "csuite_com.example.b",
+    ],
+    data = [
+        "//:android-csuite",
+    ],
+    tags = ["exclusive", "external"],
+)
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWrite_perPlan(t *testing.T) {
+	c := &converter{suite: newSuite(), runner: "//tools:run_csuite.sh"}
+	var b strings.Builder
+
+	if err := c.write(&b, []string{"launch"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.String(); strings.Count(got, "sh_test(") != 1 || strings.Contains(got, "test_suite") ||
+		!strings.Contains(got, `    name = "launch",`) {
+		t.Errorf("got:\n%s", got)
+	}
+}
+
+func TestWrite_planWithoutModules(t *testing.T) {
+	s := newSuite()
+	s.Configs["install"] = &suite.Config{Name: "install", Plan: true, Data: []byte(`<configuration>
+  <option name="compatibility:module-metadata-include-filter" key="plan" value="app-install" />
+</configuration>`)}
+	c := &converter{suite: s, runner: "//tools:run_csuite.sh", perApp: true}
+
+	if err := c.write(&strings.Builder{}, []string{"install"}); err == nil {
+		t.Error("got no error for a plan that selects no modules")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := quote(`a "b" \c`), `"a \"b\" \\c"`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// compat_matrix merges the results of runs on several devices, builds or app
+// versions into a matrix of apps against targets, written as HTML or JSON.
+//
+// Usage:
+//
+//	compat_matrix [-format html|json] [-o <file>] [<label>=]<result>...
+//
+// Each result is a test_result.xml or a results directory, and becomes the
+// column named by its label. Results without a label are named after the
+// device model and build ID they ran on. Results with the same label are
+// merged into one column: a cell shows the outcome shared by all its runs,
+// or "flaky" when the runs disagree. Each app has a row per ABI it was
+// tested on, so that results on different ABIs are not merged.
+// test_result.xml does not record app versions, so comparing app versions
+// takes labels such as "v2=results/2".
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+// flaky is the outcome of a cell whose runs disagree.
+const flaky = "flaky"
+
+type matrix struct {
+	Columns []string `json:"columns"`
+	Rows    []row    `json:"rows"`
+}
+
+type row struct {
+	Package string `json:"package"`
+	ABI     string `json:"abi"`
+	// Cells holds one outcome per column, or "" when the app was not run
+	// for that column.
+	Cells []string `json:"cells"`
+}
+
+// run is a labeled result.
+type run struct {
+	label string
+	rows  []result.Row
+}
+
+// parseArg splits a label=path argument. Paths containing "=" need a label.
+func parseArg(arg string) (label, path string) {
+	if i := strings.Index(arg, "="); i > 0 {
+		return arg[:i], arg[i+1:]
+	}
+	return "", arg
+}
+
+func defaultLabel(b result.Build) string {
+	label := strings.TrimSpace(b.Model + " " + b.ID)
+	if label == "" {
+		return b.Fingerprint
+	}
+	return label
+}
+
+// build returns the matrix of runs, with columns in the order first seen and
+// rows sorted by package and ABI.
+func build(runs []run) *matrix {
+	type key struct{ pkg, abi string }
+	m := &matrix{}
+	column := make(map[string]int)
+	outcomes := make(map[key]map[int]string)
+	for _, r := range runs {
+		c, ok := column[r.label]
+		if !ok {
+			c = len(m.Columns)
+			column[r.label] = c
+			m.Columns = append(m.Columns, r.label)
+		}
+		for _, res := range r.rows {
+			k := key{res.Package, res.ABI}
+			if outcomes[k] == nil {
+				outcomes[k] = make(map[int]string)
+			}
+			outcomes[k][c] = merge(outcomes[k][c], res.Outcome)
+		}
+	}
+
+	for k, cells := range outcomes {
+		r := row{Package: k.pkg, ABI: k.abi, Cells: make([]string, len(m.Columns))}
+		for c, outcome := range cells {
+			r.Cells[c] = outcome
+		}
+		m.Rows = append(m.Rows, r)
+	}
+	sort.Slice(m.Rows, func(i, j int) bool {
+		if m.Rows[i].Package != m.Rows[j].Package {
+			return m.Rows[i].Package < m.Rows[j].Package
+		}
+		return m.Rows[i].ABI < m.Rows[j].ABI
+	})
+	return m
+}
+
+func merge(current, outcome string) string {
+	if current == "" || current == outcome {
+		return outcome
+	}
+	return flaky
+}
+
+var htmlTemplate = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>C-Suite compatibility matrix</title>
+<style>
+table { border-collapse: collapse; font-family: sans-serif; font-size: 13px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; }
+td.pass { background: #c8e6c9; }
+td.fail { background: #ffcdd2; }
+td.crash { background: #ef9a9a; }
+td.flaky { background: #fff59d; }
+td.skip { background: #eeeeee; }
+</style>
+</head>
+<body>
+<table>
+<tr><th>Package</th><th>ABI</th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Package}}</td><td>{{.ABI}}</td>{{range .Cells}}<td class="{{.}}">{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (m *matrix) write(w io.Writer, format string) error {
+	switch format {
+	case "html":
+		return htmlTemplate.Execute(w, m)
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(m)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	format := flag.String("format", "html", "output format: html or json")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-format html|json] [-o <file>] [<label>=]<result>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || (*format != "html" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var runs []run
+	for _, arg := range flag.Args() {
+		label, path := parseArg(arg)
+		r, err := result.ParseFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if label == "" {
+			label = defaultLabel(r.Build)
+		}
+		runs = append(runs, run{label: label, rows: r.Rows()})
+	}
+
+	var f *os.File
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		w = f
+	}
+	err := build(runs).write(w, *format)
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+func TestBuild_mergesRunsByLabel(t *testing.T) {
+	runs := []run{
+		{label: "Pixel 4", rows: []result.Row{pkg("com.example.b", result.Pass), pkg("com.example.a", result.Crash)}},
+		{label: "Pixel 5", rows: []result.Row{pkg("com.example.a", result.Pass)}},
+		{label: "Pixel 4", rows: []result.Row{pkg("com.example.b", result.Fail), pkg("com.example.a", result.Crash)}},
+	}
+
+	got := build(runs)
+
+	want := &matrix{
+		Columns: []string{"Pixel 4", "Pixel 5"},
+		Rows: []row{
+			{Package: "com.example.a", Cells: []string{result.Crash, result.Pass}},
+			{Package: "com.example.b", Cells: []string{flaky, ""}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBuild_keepsABIsApart(t *testing.T) {
+	arm64 := pkg("com.example.a", result.Pass)
+	arm64.ABI = "arm64-v8a"
+	arm := pkg("com.example.a", result.Fail)
+	arm.ABI = "armeabi-v7a"
+
+	got := build([]run{{label: "Pixel 5", rows: []result.Row{arm64, arm}}})
+
+	want := []row{
+		{Package: "com.example.a", ABI: "arm64-v8a", Cells: []string{result.Pass}},
+		{Package: "com.example.a", ABI: "armeabi-v7a", Cells: []string{result.Fail}},
+	}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Errorf("got %+v, want %+v", got.Rows, want)
+	}
+}
+
+func TestParseArg(t *testing.T) {
+	if label, path := parseArg("v2=results/2"); label != "v2" || path != "results/2" {
+		t.Errorf("got %q, %q", label, path)
+	}
+	if label, path := parseArg("results/1"); label != "" || path != "results/1" {
+		t.Errorf("got %q, %q", label, path)
+	}
+}
+
+func TestDefaultLabel(t *testing.T) {
+	if got := defaultLabel(result.Build{Model: "Pixel 5", ID: "RQ1A"}); got != "Pixel 5 RQ1A" {
+		t.Errorf("got %q", got)
+	}
+	if got := defaultLabel(result.Build{Fingerprint: "google/redfin"}); got != "google/redfin" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWrite_htmlEscapesAndColorsCells(t *testing.T) {
+	m := &matrix{
+		Columns: []string{"<device>"},
+		Rows:    []row{{Package: "com.example.a", ABI: "arm64-v8a", Cells: []string{result.Crash}}},
+	}
+	var b bytes.Buffer
+
+	if err := m.write(&b, "html"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"<th>&lt;device&gt;</th>", `<td>com.example.a</td><td>arm64-v8a</td><td class="crash">crash</td>`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func pkg(name, outcome string) result.Row {
+	return result.Row{Package: name, Outcome: outcome}
+}
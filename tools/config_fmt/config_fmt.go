@@ -0,0 +1,333 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// config_fmt formats Tradefed configs, templates and plans in a canonical
+// layout, like gofmt does for Go code.
+//
+// Usage:
+//
+//	config_fmt [-w | -check] <file or dir>...
+//
+// Without flags, the formatted file is written to stdout. With -w, files are
+// rewritten in place. With -check, the files that are not formatted are
+// listed and config_fmt exits with status 1, for use in presubmit checks.
+// Directories are searched for .xml files; files whose root element is not
+// <configuration> are left alone.
+//
+// The canonical layout has the XML declaration on the first line, one
+// element per line indented by four spaces per level as in generated
+// configs, attributes in the order name, key, class, description, default,
+// value and then alphabetically, double-quoted attribute values, and
+// self-closing empty elements. Empty elements are closed with "/>" or " />"
+// as the first self-closing element of the file is, so that both configs
+// written by generate_module.py and by the Go tools are left unchanged.
+// Comments are kept, and so are single blank lines between elements, which
+// are commonly used to group options. The lines of header comments before
+// the root element, such as the license header, are aligned with the text
+// after "<!-- ", keeping their relative indentation, and have trailing
+// spaces removed.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	declaration = `<?xml version="1.0" encoding="utf-8"?>`
+	indent      = "    "
+)
+
+// attrOrder is the position of attributes that are sorted first.
+var attrOrder = map[string]int{
+	"name":        1,
+	"key":         2,
+	"class":       3,
+	"description": 4,
+	"default":     5,
+	"value":       6,
+}
+
+// errNotConfig is returned by format for XML files that are not configs.
+var errNotConfig = fmt.Errorf("root element is not <%s>", tfconfig.RootElement)
+
+func attrName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+func sortAttrs(attrs []xml.Attr) {
+	sort.SliceStable(attrs, func(i, j int) bool {
+		a, b := attrName(attrs[i].Name), attrName(attrs[j].Name)
+		oa, ob := attrOrder[a], attrOrder[b]
+		if oa == 0 || ob == 0 {
+			if oa != ob {
+				return ob == 0
+			}
+			return a < b
+		}
+		return oa < ob
+	})
+}
+
+// selfClosing returns how the first self-closing element of data is closed,
+// "/>" or " />", defaulting to " />".
+func selfClosing(data []byte) string {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			return " />"
+		}
+		if _, ok := tok.(xml.StartElement); ok {
+			end := int(d.InputOffset())
+			if bytes.HasSuffix(data[:end], []byte("/>")) {
+				if end > 2 && !isSpace(data[end-3]) {
+					return "/>"
+				}
+				return " />"
+			}
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// headerComment aligns the lines after the first of a header comment with
+// the text after "<!-- ", keeping their relative indentation, and removes
+// trailing spaces.
+func headerComment(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.ReplaceAll(text, "\t", indent), "\n")
+	if len(lines) == 1 {
+		return text
+	}
+	minIndent := -1
+	for _, l := range lines[1:] {
+		if t := strings.TrimSpace(l); t != "" {
+			if n := strings.Index(l, t); minIndent < 0 || n < minIndent {
+				minIndent = n
+			}
+		}
+	}
+	last := len(lines) - 1
+	for i, l := range lines {
+		// The last line is followed by "-->" rather than a newline.
+		if i < last || strings.TrimSpace(l) == "" {
+			l = strings.TrimRight(l, " \r")
+		}
+		if i > 0 && strings.TrimSpace(l) != "" {
+			// Lines with text set minIndent, so they are at least that long.
+			l = "     " + l[minIndent:]
+		}
+		lines[i] = l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// format returns data in the canonical layout.
+func format(data []byte) ([]byte, error) {
+	if err := tfconfig.CheckWellFormed(data); err != nil {
+		return nil, err
+	}
+	closing := selfClosing(data) + "\n"
+	var b bytes.Buffer
+	b.WriteString(declaration + "\n")
+	// Raw tokens keep namespace prefixes as written.
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	// open is set while the last start tag written is still unterminated,
+	// so it can become self-closing.
+	open := false
+	// blank is set when a blank line separates the next token from the
+	// previous one, other than the declaration.
+	blank := false
+	// written is set once a token other than the declaration is written.
+	written := false
+	seenRoot := false
+	closeTag := func() {
+		if open {
+			b.WriteString(">\n")
+			open = false
+		}
+	}
+	startLine := func() {
+		if open {
+			// The first child of an element is never preceded by a
+			// blank line.
+			blank = false
+		}
+		closeTag()
+		if blank {
+			b.WriteString("\n")
+			blank = false
+		}
+		written = true
+		b.WriteString(strings.Repeat(indent, depth))
+	}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.ProcInst:
+			// The declaration is always rewritten; other processing
+			// instructions are kept.
+			if tok.Target != "xml" {
+				startLine()
+				fmt.Fprintf(&b, "<?%s %s?>\n", tok.Target, tok.Inst)
+			}
+		case xml.Directive:
+			startLine()
+			fmt.Fprintf(&b, "<!%s>\n", tok)
+		case xml.Comment:
+			startLine()
+			text := string(tok)
+			if depth == 0 && !seenRoot {
+				text = headerComment(text)
+			}
+			fmt.Fprintf(&b, "<!--%s-->\n", text)
+		case xml.CharData:
+			text := bytes.TrimSpace(tok)
+			if len(text) == 0 {
+				if written && bytes.Count(tok, []byte("\n")) > 1 {
+					blank = true
+				}
+				continue
+			}
+			startLine()
+			xml.EscapeText(&b, text)
+			b.WriteString("\n")
+		case xml.StartElement:
+			if depth == 0 {
+				if seenRoot || tok.Name.Space != "" || tok.Name.Local != tfconfig.RootElement {
+					return nil, errNotConfig
+				}
+				seenRoot = true
+			}
+			startLine()
+			b.WriteString("<" + attrName(tok.Name))
+			sortAttrs(tok.Attr)
+			for _, a := range tok.Attr {
+				fmt.Fprintf(&b, " %s=\"%s\"", attrName(a.Name), tfconfig.EscapeAttr(a.Value))
+			}
+			open = true
+			depth++
+		case xml.EndElement:
+			depth--
+			blank = false
+			if open {
+				b.WriteString(closing)
+				open = false
+				continue
+			}
+			startLine()
+			b.WriteString("</" + attrName(tok.Name) + ">\n")
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		err := filepath.WalkDir(arg, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if p == arg || filepath.Ext(p) == ".xml" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func main() {
+	write := flag.Bool("w", false, "rewrite files in place")
+	check := flag.Bool("check", false, "list files that are not formatted and exit with status 1 if any")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-w | -check] <file or dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || (*write && *check) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := collectFiles(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	status := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		formatted, err := format(data)
+		if err == errNotConfig {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			status = 2
+			continue
+		}
+		switch {
+		case *check:
+			if !bytes.Equal(data, formatted) {
+				fmt.Println(path)
+				if status == 0 {
+					status = 1
+				}
+			}
+		case *write:
+			if !bytes.Equal(data, formatted) {
+				if err := os.WriteFile(path, formatted, 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(2)
+				}
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func TestFormat(t *testing.T) {
+	input := `<?xml version='1.0'?>
+<!-- Header. -->
+<configuration
+  description="C-Suite Launch">
+
+  <include   name="csuite-base" />
+
+
+  <option value="app-launch" key="plan" name="compatibility:module-metadata-include-filter" />
+  <test class="com.android.compatibility.testtype.AppLaunchTest" >
+
+      <!-- Nested comment. -->
+      <option value="a &amp; b" name='package-name'></option>
+
+  </test>
+</configuration>`
+	want := `<?xml version="1.0" encoding="utf-8"?>
+<!-- Header. -->
+<configuration description="C-Suite Launch">
+    <include name="csuite-base" />
+
+    <option name="compatibility:module-metadata-include-filter" key="plan" value="app-launch" />
+    <test class="com.android.compatibility.testtype.AppLaunchTest">
+        <!-- Nested comment. -->
+        <option name="package-name" value="a &amp; b" />
+    </test>
+</configuration>
+`
+
+	got, err := format([]byte(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	again, err := format(got)
+	if err != nil || string(again) != want {
+		t.Errorf("formatting is not idempotent: got:\n%s", again)
+	}
+}
+
+func TestFormat_generatedConfigsAreFormatted(t *testing.T) {
+	c := &tfconfig.Configuration{
+		Description: "Tests the compatibility of apps",
+		Options:     []tfconfig.Option{{Name: "package-name", Value: "com.example"}},
+		Objects: []tfconfig.Object{{
+			XMLName: xml.Name{Local: "test"},
+			Class:   "com.android.compatibility.testtype.AppLaunchTest",
+		}},
+	}
+	generated := tfconfig.MarshalGenerated(c, "test/app_compat/csuite/tools/config_fmt")
+
+	got, err := format(generated)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(generated) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, generated)
+	}
+}
+
+func TestFormat_generateModuleConfigsAreFormatted(t *testing.T) {
+	// As written by script/generate_module.py.
+	generated := `<?xml version="1.0" encoding="utf-8"?>
+<!-- Copyright (C) 2020 The Android Open Source Project
+     Licensed under the Apache License, Version 2.0 (the "License");
+     you may not use this file except in compliance with the License.
+     You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+     Unless required by applicable law or agreed to in writing, software
+     distributed under the License is distributed on an "AS IS" BASIS,
+     WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+     See the License for the specific language governing permissions and
+     limitations under the License.
+-->
+<!-- This file was auto-generated by test/app_compat/csuite/tools/script/generate_module.py.
+     Do not edit manually.
+-->
+
+<configuration description="Tests the compatibility of apps">
+    <option name="config-descriptor:metadata" key="plan" value="csuite-launch"/>
+    <option name="package-name" value="com.example"/>
+    <target_preparer class="com.android.tradefed.targetprep.TestAppInstallSetup">
+        <option name="test-file-name" value="csuite-launch-instrumentation.apk"/>
+    </target_preparer>
+    <target_preparer class="com.android.compatibility.targetprep.AppSetupPreparer"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest"/>
+</configuration>
+`
+
+	got, err := format([]byte(generated))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != generated {
+		t.Errorf("got:\n%s\nwant:\n%s", got, generated)
+	}
+}
+
+func TestFormat_alignsLicenseHeader(t *testing.T) {
+	input := "<!-- Copyright (C) 2020 The Android Open Source Project  \n" +
+		"  Licensed under the Apache License, Version 2.0 (the \"License\");\n" +
+		"\n" +
+		"\t   http://www.apache.org/licenses/LICENSE-2.0\n" +
+		"  -->\n" +
+		"<configuration />\n"
+	want := `<?xml version="1.0" encoding="utf-8"?>
+<!-- Copyright (C) 2020 The Android Open Source Project
+     Licensed under the Apache License, Version 2.0 (the "License");
+
+          http://www.apache.org/licenses/LICENSE-2.0
+-->
+<configuration />
+`
+
+	got, err := format([]byte(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormat_crlfLicenseHeader(t *testing.T) {
+	input := "<?xml version=\"1.0\" encoding=\"utf-8\"?>\r\n" +
+		"<!-- Copyright (C) 2020 The Android Open Source Project\r\n" +
+		"     Licensed under the Apache License, Version 2.0 (the \"License\");\r\n" +
+		"\r\n" +
+		"        http://www.apache.org/licenses/LICENSE-2.0\r\n" +
+		"-->\r\n" +
+		"<configuration>\r\n" +
+		"    <option name=\"a\" value=\"b\" />\r\n" +
+		"</configuration>\r\n"
+	want := `<?xml version="1.0" encoding="utf-8"?>
+<!-- Copyright (C) 2020 The Android Open Source Project
+     Licensed under the Apache License, Version 2.0 (the "License");
+
+        http://www.apache.org/licenses/LICENSE-2.0
+-->
+<configuration>
+    <option name="a" value="b" />
+</configuration>
+`
+
+	got, err := format([]byte(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestHeaderComment_blankAndCarriageReturnLines(t *testing.T) {
+	for text, want := range map[string]string{
+		" a\r\n\r\n":        " a\n\n",
+		" a\n\r":            " a\n",
+		" a\n   b\n\r\n c ": " a\n       b\n\n     c ",
+	} {
+		if got := headerComment(text); got != want {
+			t.Errorf("headerComment(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestFormat_rejectsOtherFiles(t *testing.T) {
+	for _, input := range []string{
+		`<module name="Checker" />`,
+		`<configuration>`,
+	} {
+		if _, err := format([]byte(input)); err == nil {
+			t.Errorf("format(%q) succeeded, want error", input)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// crash_triage scans the logcat and dropbox files collected from C-Suite
+// runs, extracts a signature for every app crash and ANR, deduplicates the
+// signatures across apps and reports them grouped by likely fault, so that
+// platform regressions stand out from app bugs.
+//
+// Usage:
+//
+//	crash_triage [-json] [-frames <n>] <file or dir>...
+//
+// Directories are scanned recursively. Logcat files may be in the threadtime
+// or brief format; dropbox entries such as data_app_crash are read as they
+// are, and decompressed first if they end in .gz. Files that cannot be
+// scanned, such as binary files, are reported on stderr and skipped. Java
+// crash signatures are the exception class and the top frames, native crash
+// signatures are the signal and the top frames, and ANR signatures are the
+// ANR reason without its details.
+//
+// A crash is a likely platform fault when none of its frames are app code,
+// i.e. every frame is in a platform package or library. All other crashes
+// and ANRs are likely app faults.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Types of a crash.
+const (
+	javaCrash   = "java_crash"
+	nativeCrash = "native_crash"
+	anr         = "anr"
+)
+
+// Likely faults of a crash.
+const (
+	platformFault = "platform"
+	appFault      = "app"
+)
+
+var (
+	threadtimePattern = regexp.MustCompile(`^\d\d-\d\d \d\d:\d\d:\d\d\.\d+\s+\d+\s+\d+\s+[VDIWEFA]\s+.*?: ?(.*)$`)
+	briefPattern      = regexp.MustCompile(`^[VDIWEFA]/.*?\(\s*\d+\): ?(.*)$`)
+
+	fatalExceptionPattern = regexp.MustCompile(`^FATAL EXCEPTION:`)
+	processPattern        = regexp.MustCompile(`^Process: ([\w.]+)`)
+	exceptionPattern      = regexp.MustCompile(`^((?:[a-z][\w$]*\.)+[A-Z][\w$]*)(?::|$)`)
+	javaFramePattern      = regexp.MustCompile(`^\s*at ([\w$.<>-]+)\(`)
+	nativeProcessPattern  = regexp.MustCompile(`>>> ([\w.:]+) <<<`)
+	signalPattern         = regexp.MustCompile(`signal \d+ \((SIG\w+)\)`)
+	nativeFramePattern    = regexp.MustCompile(`^\s*#\d+ pc [0-9a-f]+\s+(\S+)(?: \((.+?)(?:\+\d+)?\)(?: |$))?`)
+	anrPattern            = regexp.MustCompile(`^ANR in ([\w.:]+)`)
+	anrReasonPattern      = regexp.MustCompile(`^(?:Reason|Subject): (.*)`)
+)
+
+var platformJavaPrefixes = []string{"android.", "com.android.", "java.", "javax.", "dalvik.", "libcore.", "sun."}
+
+var platformLibraryPrefixes = []string{"/system/", "/apex/", "/vendor/", "/system_ext/", "[vdso]"}
+
+type crash struct {
+	Type    string
+	Package string
+	// Head is the exception class, signal or ANR reason.
+	Head   string
+	Frames []string
+	File   string
+}
+
+// signature identifies crashes with the same cause, using the top n frames.
+func (c *crash) signature(n int) string {
+	frames := c.Frames
+	if len(frames) > n {
+		frames = frames[:n]
+	}
+	return strings.Join(append([]string{c.Type, c.Head}, frames...), " | ")
+}
+
+// fault returns the likely fault of the crash.
+func (c *crash) fault() string {
+	if c.Type == anr || len(c.Frames) == 0 {
+		return appFault
+	}
+	prefixes := platformJavaPrefixes
+	if c.Type == nativeCrash {
+		prefixes = platformLibraryPrefixes
+	}
+	for _, f := range c.Frames {
+		if !hasAnyPrefix(f, prefixes) {
+			return appFault
+		}
+	}
+	return platformFault
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// message strips the logcat prefix from line, if any.
+func message(line string) string {
+	for _, p := range []*regexp.Regexp{threadtimePattern, briefPattern} {
+		if m := p.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return line
+}
+
+// scanner extracts crashes from the lines of one file.
+type scanner struct {
+	file    string
+	current *crash
+	crashes []crash
+}
+
+func (s *scanner) start(c *crash) {
+	s.finish()
+	c.File = s.file
+	s.current = c
+}
+
+func (s *scanner) finish() {
+	if s.current != nil && s.current.Head != "" {
+		s.crashes = append(s.crashes, *s.current)
+	}
+	s.current = nil
+}
+
+func (s *scanner) line(msg string) {
+	c := s.current
+	switch {
+	case fatalExceptionPattern.MatchString(msg):
+		s.start(&crash{Type: javaCrash})
+		return
+	case anrPattern.MatchString(msg):
+		s.start(&crash{Type: anr, Package: anrPattern.FindStringSubmatch(msg)[1]})
+		return
+	case nativeProcessPattern.MatchString(msg):
+		pkg := nativeProcessPattern.FindStringSubmatch(msg)[1]
+		if c == nil || c.Type != nativeCrash || len(c.Frames) > 0 {
+			s.start(&crash{Type: nativeCrash})
+		}
+		s.current.Package = pkg
+		return
+	case processPattern.MatchString(msg):
+		pkg := processPattern.FindStringSubmatch(msg)[1]
+		// A dropbox entry starts with its process; logcat crashes report it
+		// after FATAL EXCEPTION.
+		if c == nil || c.Package != "" || c.Head != "" {
+			s.start(&crash{Type: javaCrash})
+		}
+		s.current.Package = pkg
+		return
+	}
+	if c == nil {
+		return
+	}
+
+	switch c.Type {
+	case javaCrash:
+		if m := anrReasonPattern.FindStringSubmatch(msg); m != nil && c.Head == "" {
+			c.Type = anr
+			c.Head = anrReason(m[1])
+			s.finish()
+		} else if m := javaFramePattern.FindStringSubmatch(msg); m != nil && c.Head != "" {
+			c.Frames = append(c.Frames, m[1])
+		} else if m := exceptionPattern.FindStringSubmatch(msg); m != nil && c.Head == "" {
+			c.Head = m[1]
+		} else if len(c.Frames) > 0 {
+			// The first stack trace ends at its first non-frame line, e.g.
+			// "Caused by:" or "... 12 more".
+			s.finish()
+		}
+	case nativeCrash:
+		if m := signalPattern.FindStringSubmatch(msg); m != nil && c.Head == "" {
+			c.Head = m[1]
+		} else if m := nativeFramePattern.FindStringSubmatch(msg); m != nil {
+			frame := m[1]
+			if !hasAnyPrefix(frame, platformLibraryPrefixes) {
+				// App library paths contain a random install directory.
+				frame = path.Base(frame)
+			}
+			if m[2] != "" {
+				frame += " (" + m[2] + ")"
+			}
+			c.Frames = append(c.Frames, frame)
+		} else if len(c.Frames) > 0 && strings.TrimSpace(msg) != "" {
+			s.finish()
+		}
+	case anr:
+		if m := anrReasonPattern.FindStringSubmatch(msg); m != nil {
+			c.Head = anrReason(m[1])
+			s.finish()
+		}
+	}
+}
+
+// anrReason drops the details that differ between occurrences of the same
+// ANR, such as the window and the time waited.
+func anrReason(reason string) string {
+	if i := strings.Index(reason, " ("); i > 0 {
+		reason = reason[:i]
+	}
+	return strings.TrimSpace(reason)
+}
+
+// scan returns the crashes found in r.
+func scan(file string, r io.Reader) ([]crash, error) {
+	s := &scanner{file: file}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		s.line(message(sc.Text()))
+	}
+	s.finish()
+	return s.crashes, sc.Err()
+}
+
+type group struct {
+	Signature string   `json:"signature"`
+	Type      string   `json:"type"`
+	Fault     string   `json:"fault"`
+	Count     int      `json:"count"`
+	Packages  []string `json:"packages"`
+	Files     []string `json:"files"`
+}
+
+// dedup groups crashes by signature, most frequent first within each
+// fault.
+func dedup(crashes []crash, frames int) []group {
+	bySignature := make(map[string]*group)
+	for _, c := range crashes {
+		sig := c.signature(frames)
+		g, ok := bySignature[sig]
+		if !ok {
+			g = &group{Signature: sig, Type: c.Type, Fault: c.fault()}
+			bySignature[sig] = g
+		}
+		g.Count++
+		g.Packages = appendUnique(g.Packages, c.Package)
+		g.Files = appendUnique(g.Files, c.File)
+	}
+
+	var groups []group
+	for _, g := range bySignature {
+		sort.Strings(g.Packages)
+		sort.Strings(g.Files)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.Fault != b.Fault {
+			return a.Fault == platformFault
+		}
+		if len(a.Packages) != len(b.Packages) {
+			return len(a.Packages) > len(b.Packages)
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Signature < b.Signature
+	})
+	return groups
+}
+
+func appendUnique(list []string, s string) []string {
+	if s == "" {
+		return list
+	}
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func writeReport(w io.Writer, groups []group) {
+	for _, fault := range []string{platformFault, appFault} {
+		fmt.Fprintf(w, "Likely %s faults:\n", fault)
+		n := 0
+		for _, g := range groups {
+			if g.Fault != fault {
+				continue
+			}
+			n++
+			fmt.Fprintf(w, "  %s\n", g.Signature)
+			fmt.Fprintf(w, "      %d occurrence(s) in %d app(s): %s\n", g.Count, len(g.Packages), strings.Join(g.Packages, ", "))
+		}
+		if n == 0 {
+			fmt.Fprintln(w, "  none")
+		}
+	}
+}
+
+// scanFile scans a file, decompressing it first if it is gzipped, as
+// dropbox entries such as data_app_crash@<time>.txt.gz are.
+func scanFile(p string) ([]crash, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := io.Reader(f)
+	if path.Ext(p) == ".gz" {
+		z, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+		r = z
+	}
+	return scan(p, r)
+}
+
+// collect scans the files under root. Files that cannot be scanned, e.g.
+// binary files, are reported to warn and skipped.
+func collect(root string, warn io.Writer) ([]crash, error) {
+	var crashes []crash
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := path.Ext(d.Name()); ext == ".zip" || ext == ".apk" || ext == ".png" {
+			return nil
+		}
+		found, err := scanFile(p)
+		if err != nil {
+			fmt.Fprintf(warn, "skipping %s: %v\n", p, err)
+		}
+		crashes = append(crashes, found...)
+		return nil
+	})
+	return crashes, err
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	frames := flag.Int("frames", 3, "number of top frames in a signature")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] [-frames <n>] <file or dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *frames < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var crashes []crash
+	for _, root := range flag.Args() {
+		found, err := collect(root, os.Stderr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		crashes = append(crashes, found...)
+	}
+
+	groups := dedup(crashes, *frames)
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if groups == nil {
+			groups = []group{}
+		}
+		e.Encode(groups)
+		return
+	}
+	writeReport(os.Stdout, groups)
+}
@@ -0,0 +1,190 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const logcat = `10-16 12:00:00.000  1234  1234 I ActivityManager: Start proc 1234:com.example.a/u0a100
+10-16 12:00:01.000  1234  1234 E AndroidRuntime: FATAL EXCEPTION: main
+10-16 12:00:01.000  1234  1234 E AndroidRuntime: Process: com.example.a, PID: 1234
+10-16 12:00:01.000  1234  1234 E AndroidRuntime: java.lang.NullPointerException: Attempt to invoke virtual method
+10-16 12:00:01.000  1234  1234 E AndroidRuntime: 	at com.example.a.Main.onCreate(Main.java:10)
+10-16 12:00:01.000  1234  1234 E AndroidRuntime: 	at android.app.Activity.performCreate(Activity.java:8000)
+10-16 12:00:01.000  1234  1234 E AndroidRuntime: Caused by: java.lang.IllegalStateException
+10-16 12:00:02.000   500   510 E ActivityManager: ANR in com.example.b (com.example.b/.Main)
+10-16 12:00:02.000   500   510 E ActivityManager: PID: 2345
+10-16 12:00:02.000   500   510 E ActivityManager: Reason: Input dispatching timed out (Waited 5003ms for MotionEvent)
+10-16 12:00:03.000  3456  3456 F DEBUG   : *** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+10-16 12:00:03.000  3456  3456 F DEBUG   : pid: 3456, tid: 3456, name: main  >>> com.example.c <<<
+10-16 12:00:03.000  3456  3456 F DEBUG   : signal 11 (SIGSEGV), code 1 (SEGV_MAPERR), fault addr 0x0
+10-16 12:00:03.000  3456  3456 F DEBUG   : backtrace:
+10-16 12:00:03.000  3456  3456 F DEBUG   :       #00 pc 000000000004a2b0  /apex/com.android.runtime/lib64/bionic/libc.so (strlen+16) (BuildId: abc)
+10-16 12:00:03.000  3456  3456 F DEBUG   :       #01 pc 0000000000001234  /data/app/~~Xyz==/com.example.c-1/lib/arm64/libgame.so (Game::init()+8)
+10-16 12:00:03.000  3456  3456 F DEBUG   : stack:
+`
+
+const dropboxCrash = `Process: com.example.d
+PID: 4567
+Flags: 0x38c83e44
+Package: com.example.d v1 (1.0)
+Build: google/device/device:11/RP1A/1:userdebug/dev-keys
+
+java.lang.IllegalStateException: Fragment not attached
+	at android.app.Fragment.requireActivity(Fragment.java:100)
+	at android.app.Fragment.getResources(Fragment.java:200)
+`
+
+func TestScan_logcat_findsAllCrashTypes(t *testing.T) {
+	got, err := scan("logcat.txt", strings.NewReader(logcat))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []crash{
+		{Type: javaCrash, Package: "com.example.a", Head: "java.lang.NullPointerException",
+			Frames: []string{"com.example.a.Main.onCreate", "android.app.Activity.performCreate"}, File: "logcat.txt"},
+		{Type: anr, Package: "com.example.b", Head: "Input dispatching timed out", File: "logcat.txt"},
+		{Type: nativeCrash, Package: "com.example.c", Head: "SIGSEGV",
+			Frames: []string{"/apex/com.android.runtime/lib64/bionic/libc.so (strlen)", "libgame.so (Game::init())"},
+			File:   "logcat.txt"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestScan_dropboxEntry(t *testing.T) {
+	got, err := scan("data_app_crash", strings.NewReader(dropboxCrash))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Package != "com.example.d" || got[0].Head != "java.lang.IllegalStateException" ||
+		len(got[0].Frames) != 2 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestCollect_gzippedEntryAndUnscannableFile(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	z := gzip.NewWriter(&gz)
+	z.Write([]byte(dropboxCrash))
+	z.Close()
+	if err := os.WriteFile(filepath.Join(dir, "data_app_crash@1.txt.gz"), gz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A line longer than the scanner buffer.
+	if err := os.WriteFile(filepath.Join(dir, "long.txt"), bytes.Repeat([]byte("x"), 2*1024*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var warn strings.Builder
+
+	got, err := collect(dir, &warn)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Package != "com.example.d" {
+		t.Errorf("got %+v", got)
+	}
+	if !strings.Contains(warn.String(), "long.txt") {
+		t.Errorf("got warnings %q, want long.txt reported", warn.String())
+	}
+}
+
+func TestScan_briefLogcat(t *testing.T) {
+	data := "E/AndroidRuntime( 1234): FATAL EXCEPTION: main\n" +
+		"E/AndroidRuntime( 1234): Process: com.example.a, PID: 1234\n" +
+		"E/AndroidRuntime( 1234): java.lang.RuntimeException\n" +
+		"E/AndroidRuntime( 1234): \tat com.example.a.Main.run(Main.java:1)\n"
+
+	got, err := scan("logcat.txt", strings.NewReader(data))
+
+	if err != nil || len(got) != 1 || got[0].Head != "java.lang.RuntimeException" || len(got[0].Frames) != 1 {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
+
+func TestFault(t *testing.T) {
+	for _, tc := range []struct {
+		c    crash
+		want string
+	}{
+		{crash{Type: javaCrash, Frames: []string{"android.app.Fragment.requireActivity", "java.lang.Thread.run"}}, platformFault},
+		{crash{Type: javaCrash, Frames: []string{"android.app.Activity.performCreate", "com.example.a.Main.onCreate"}}, appFault},
+		{crash{Type: nativeCrash, Frames: []string{"/system/lib64/libhwui.so (draw)"}}, platformFault},
+		{crash{Type: nativeCrash, Frames: []string{"/apex/libc.so (abort)", "libgame.so (main)"}}, appFault},
+		{crash{Type: anr}, appFault},
+	} {
+		if got := tc.c.fault(); got != tc.want {
+			t.Errorf("%+v: got %s, want %s", tc.c, got, tc.want)
+		}
+	}
+}
+
+func TestDedup_groupsSameSignatureAcrossApps(t *testing.T) {
+	frames := []string{"android.view.View.draw", "android.view.ViewGroup.draw", "android.view.View.draw", "com.example.x.V.draw"}
+	crashes := []crash{
+		{Type: javaCrash, Package: "com.example.a", Head: "java.lang.NullPointerException", Frames: frames, File: "a"},
+		{Type: javaCrash, Package: "com.example.b", Head: "java.lang.NullPointerException", Frames: frames[:3], File: "b"},
+		{Type: javaCrash, Package: "com.example.b", Head: "java.lang.NullPointerException", Frames: frames[:3], File: "b"},
+		{Type: anr, Package: "com.example.c", Head: "Input dispatching timed out", File: "c"},
+	}
+
+	got := dedup(crashes, 3)
+
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want 2 groups", got)
+	}
+	if g := got[1]; g.Fault != appFault || g.Type != anr {
+		t.Errorf("got second group %+v, want the ANR", g)
+	}
+	// Only the top 3 frames are in the signature, so the first crash shares
+	// the signature of the others even though its stack has app code below.
+	want := group{
+		Signature: "java_crash | java.lang.NullPointerException | android.view.View.draw | android.view.ViewGroup.draw | android.view.View.draw",
+		Type:      javaCrash, Fault: appFault, Count: 3,
+		Packages: []string{"com.example.a", "com.example.b"}, Files: []string{"a", "b"},
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("got %+v\nwant %+v", got[0], want)
+	}
+}
+
+func TestWriteReport_groupsByFault(t *testing.T) {
+	var b bytes.Buffer
+
+	writeReport(&b, []group{{Signature: "anr | Input dispatching timed out", Fault: appFault, Count: 2,
+		Packages: []string{"com.example.a"}}})
+
+	want := `Likely platform faults:
+  none
+Likely app faults:
+  anr | Input dispatching timed out
+      2 occurrence(s) in 1 app(s): com.example.a
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
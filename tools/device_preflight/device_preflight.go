@@ -0,0 +1,273 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// device_preflight checks that a device and a suite are ready for a run of
+// a plan, so that problems show up before a multi-hour run rather than
+// during it.
+//
+// Usage:
+//
+//	device_preflight [-adb <path>] [-s <serial>] [-gcs_apk_dir <dir>] [-feature <name>]... [-option <name>]... <suite zip or dir> <plan>
+//
+// The checks are:
+//
+//   - the device is online and has finished booting;
+//   - the device has every feature given with -feature, as listed by
+//     pm list features;
+//   - every option given with -option, e.g. account options, is set by the
+//     plan or a config it includes;
+//   - the plan selects at least one module, by the plan metadata filter it
+//     sets, and every test-file-name of those modules is in testcases;
+//   - when the plan uses AppSetupPreparer, the APK directory is set, with
+//     -gcs_apk_dir or the gcs-apk-dir option, and holds an APK for the
+//     package of every module, as AppSetupPreparer requires.
+//
+// Every check is printed with PASS or FAIL, and device_preflight exits with
+// status 1 if any check fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	packageNameOption     = "package-name"
+	testFileNameOption    = "test-file-name"
+	gcsApkDirOption       = "gcs-apk-dir"
+	appSetupPreparerClass = "com.android.compatibility.targetprep.AppSetupPreparer"
+)
+
+type result struct {
+	name   string
+	ok     bool
+	detail string
+}
+
+func (r result) String() string {
+	status := "PASS"
+	if !r.ok {
+		status = "FAIL"
+	}
+	if r.detail == "" {
+		return status + " " + r.name
+	}
+	return status + " " + r.name + ": " + r.detail
+}
+
+// runAdb runs an adb command on the device. It is a variable so that tests
+// can run without a device.
+var runAdb = func(adb, serial string, args ...string) (string, error) {
+	if serial != "" {
+		args = append([]string{"-s", serial}, args...)
+	}
+	out, err := exec.Command(adb, args...).Output()
+	if e, ok := err.(*exec.ExitError); ok {
+		return "", fmt.Errorf("adb %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(e.Stderr)))
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+type preflight struct {
+	adb, serial string
+	suite       *suite.Suite
+	plan        string
+	gcsApkDir   string
+	features    []string
+	options     []string
+}
+
+// checkDevice checks that the device is online, booted and has the
+// required features.
+func (p *preflight) checkDevice() []result {
+	state, err := runAdb(p.adb, p.serial, "get-state")
+	if err != nil || state != "device" {
+		detail := "state " + state
+		if err != nil {
+			detail = err.Error()
+		}
+		return []result{{name: "device online", detail: detail}}
+	}
+	results := []result{{name: "device online", ok: true}}
+
+	booted, err := runAdb(p.adb, p.serial, "shell", "getprop", "sys.boot_completed")
+	if err != nil {
+		results = append(results, result{name: "device booted", detail: err.Error()})
+	} else {
+		results = append(results, result{name: "device booted", ok: booted == "1"})
+	}
+
+	if len(p.features) == 0 {
+		return results
+	}
+	out, err := runAdb(p.adb, p.serial, "shell", "pm", "list", "features")
+	if err != nil {
+		return append(results, result{name: "device features", detail: err.Error()})
+	}
+	have := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		name := strings.TrimPrefix(strings.TrimSpace(line), "feature:")
+		name, _, _ = strings.Cut(name, "=")
+		have[name] = true
+	}
+	for _, f := range p.features {
+		results = append(results, result{name: "feature " + f, ok: have[f]})
+	}
+	return results
+}
+
+func hasAPK(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(p, ".apk") {
+			found = true
+			return filepath.SkipDir
+		}
+		return err
+	})
+	return found
+}
+
+// checkSuite checks the plan options and the modules it selects.
+func (p *preflight) checkSuite() ([]result, error) {
+	configs, err := p.suite.PlanConfigs(p.plan)
+	if err != nil {
+		return nil, err
+	}
+	var options []tfconfig.Option
+	usesAppSetup := false
+	for _, c := range configs {
+		options = append(options, c.AllOptions()...)
+		usesAppSetup = usesAppSetup || len(c.ObjectsOfClass(appSetupPreparerClass)) > 0
+	}
+
+	var results []result
+	for _, name := range p.options {
+		results = append(results, result{name: "option " + name, ok: len(tfconfig.OptionValues(options, name)) > 0})
+	}
+
+	selected, err := p.suite.PlanModules(p.plan)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, result{name: "modules selected", ok: len(selected) > 0,
+		detail: fmt.Sprintf("%d modules", len(selected))})
+
+	// PlanModules sorts the modules by name, so names and the reports built
+	// from it are in a stable order.
+	var names []string
+	modules := make(map[string]*tfconfig.Configuration)
+	for _, m := range selected {
+		parsed, err := tfconfig.Parse(m.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.Path, err)
+		}
+		names = append(names, m.Name)
+		modules[m.Name] = parsed
+		usesAppSetup = usesAppSetup || len(parsed.ObjectsOfClass(appSetupPreparerClass)) > 0
+	}
+
+	var missingFiles []string
+	for _, name := range names {
+		for _, f := range tfconfig.OptionValues(modules[name].AllOptions(), testFileNameOption) {
+			if len(p.suite.TestFiles) > 0 && !p.suite.HasTestFile(f) {
+				missingFiles = append(missingFiles, name+": "+f)
+			}
+		}
+	}
+	results = append(results, result{name: "test files present", ok: len(missingFiles) == 0,
+		detail: strings.Join(missingFiles, ", ")})
+
+	if !usesAppSetup {
+		return results, nil
+	}
+	apkDir := p.gcsApkDir
+	if apkDir == "" {
+		if dirs := tfconfig.OptionValues(options, gcsApkDirOption); len(dirs) > 0 {
+			apkDir = dirs[len(dirs)-1]
+		}
+	}
+	if apkDir == "" {
+		return append(results, result{name: "APK directory set",
+			detail: "set -gcs_apk_dir or the " + gcsApkDirOption + " option"}), nil
+	}
+	if info, err := os.Stat(apkDir); err != nil || !info.IsDir() {
+		return append(results, result{name: "APK directory set", detail: apkDir + " is not a directory"}), nil
+	}
+	results = append(results, result{name: "APK directory set", ok: true, detail: apkDir})
+
+	var missing []string
+	for _, name := range names {
+		for _, pkg := range tfconfig.OptionValues(modules[name].AllOptions(), packageNameOption) {
+			if !hasAPK(filepath.Join(apkDir, pkg)) {
+				missing = append(missing, pkg)
+			}
+		}
+	}
+	return append(results, result{name: "APKs present", ok: len(missing) == 0,
+		detail: strings.Join(missing, ", ")}), nil
+}
+
+func main() {
+	var features, options flagutil.StringList
+	p := &preflight{}
+	flag.StringVar(&p.adb, "adb", "adb", "path to adb")
+	flag.StringVar(&p.serial, "s", os.Getenv("ANDROID_SERIAL"), "serial of the device; defaults to $ANDROID_SERIAL")
+	flag.StringVar(&p.gcsApkDir, "gcs_apk_dir", "", "directory of the APKs to test, overriding the "+gcsApkDirOption+" option")
+	flag.Var(&features, "feature", "device feature the plan needs; may be repeated")
+	flag.Var(&options, "option", "option the plan must set; may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-adb <path>] [-s <serial>] [-gcs_apk_dir <dir>] [-feature <name>]... [-option <name>]... <suite zip or dir> <plan>\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p.features, p.options, p.plan = features, options, flag.Arg(1)
+
+	s, err := suite.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	p.suite = s
+
+	results, err := p.checkSuite()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	results = append(p.checkDevice(), results...)
+	failed := false
+	for _, r := range results {
+		fmt.Println(r)
+		failed = failed || !r.ok
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+const module = `<configuration>
+    <option name="config-descriptor:metadata" key="plan" value="%s" />
+    <option name="package-name" value="%s" />
+    <target_preparer class="com.android.tradefed.targetprep.TestAppInstallSetup">
+        <option name="test-file-name" value="csuite-launch-instrumentation.apk" />
+    </target_preparer>
+    <target_preparer class="com.android.compatibility.targetprep.AppSetupPreparer" />
+</configuration>`
+
+func newModule(plan, pkg string) string {
+	return strings.Replace(strings.Replace(module, "%s", plan, 1), "%s", pkg, 1)
+}
+
+func newSuite() *suite.Suite {
+	s := suite.New()
+	s.Configs["launch"] = &suite.Config{Name: "launch", Plan: true, Data: []byte(`<configuration>
+  <include name="csuite-base" />
+  <option name="compatibility:module-metadata-include-filter" key="plan" value="csuite-launch" />
+</configuration>`)}
+	s.Configs["csuite-base"] = &suite.Config{Name: "csuite-base", Plan: true, Data: []byte(`<configuration>
+  <include name="everything" />
+  <option name="account" value="test@example.com" />
+</configuration>`)}
+	s.Modules["csuite_com.example.a"] = &suite.Config{Name: "csuite_com.example.a", Data: []byte(newModule("csuite-launch", "com.example.a"))}
+	s.Modules["csuite_com.example.b"] = &suite.Config{Name: "csuite_com.example.b", Data: []byte(newModule("csuite-launch", "com.example.b"))}
+	s.Modules["csuite_com.example.c"] = &suite.Config{Name: "csuite_com.example.c", Data: []byte(newModule("csuite-crawl", "com.example.c"))}
+	s.TestFiles["csuite_com.example.a/csuite-launch-instrumentation.apk"] = true
+	return s
+}
+
+func TestCheckDevice(t *testing.T) {
+	runAdb = func(adb, serial string, args ...string) (string, error) {
+		switch strings.Join(args, " ") {
+		case "get-state":
+			return "device", nil
+		case "shell getprop sys.boot_completed":
+			return "1", nil
+		case "shell pm list features":
+			return "feature:android.hardware.touchscreen\nfeature:android.software.webview\nfeature:reqGlEsVersion=0x30002", nil
+		}
+		t.Fatalf("unexpected adb %v", args)
+		return "", nil
+	}
+	p := &preflight{features: []string{"android.software.webview", "android.hardware.telephony"}}
+
+	got := p.checkDevice()
+
+	want := []result{
+		{name: "device online", ok: true},
+		{name: "device booted", ok: true},
+		{name: "feature android.software.webview", ok: true},
+		{name: "feature android.hardware.telephony"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckDevice_offline(t *testing.T) {
+	runAdb = func(adb, serial string, args ...string) (string, error) {
+		return "offline", nil
+	}
+
+	got := (&preflight{}).checkDevice()
+
+	if want := []result{{name: "device online", detail: "state offline"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckSuite(t *testing.T) {
+	apkDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(apkDir, "com.example.a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(apkDir, "com.example.a", "base.apk"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &preflight{suite: newSuite(), plan: "launch", gcsApkDir: apkDir, options: []string{"account", "password"}}
+
+	got, err := p.checkSuite()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []result{
+		{name: "option account", ok: true},
+		{name: "option password"},
+		{name: "modules selected", ok: true, detail: "2 modules"},
+		{name: "test files present", ok: true},
+		{name: "APK directory set", ok: true, detail: apkDir},
+		{name: "APKs present", detail: "com.example.b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v,\nwant %+v", got, want)
+	}
+}
+
+func TestCheckSuite_noAPKDirectory(t *testing.T) {
+	p := &preflight{suite: newSuite(), plan: "launch"}
+
+	got, err := p.checkSuite()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := got[len(got)-1]; last.name != "APK directory set" || last.ok {
+		t.Errorf("got %+v, want a failed APK directory check", last)
+	}
+}
+
+func TestCheckSuite_missingTestFiles_reportedInModuleOrder(t *testing.T) {
+	s := newSuite()
+	s.TestFiles = map[string]bool{"other.apk": true}
+	p := &preflight{suite: s, plan: "launch"}
+
+	got, err := p.checkSuite()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := result{name: "test files present",
+		detail: "csuite_com.example.a: csuite-launch-instrumentation.apk, csuite_com.example.b: csuite-launch-instrumentation.apk"}
+	if got[1] != want {
+		t.Errorf("got %+v, want %+v", got[1], want)
+	}
+}
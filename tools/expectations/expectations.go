@@ -0,0 +1,330 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// expectations manages the expectation file of a plan: the apps known to
+// fail, each with a bug and an expiry date, and turns it into the exclude
+// filters that keep those apps out of the plan's runs.
+//
+// Usage:
+//
+//	expectations add -file <file> -package <name> -bug <bug> -expires <YYYY-MM-DD> [-reason <text>]
+//	expectations remove -file <file> -package <name>
+//	expectations update -file <file> [-bug <bug> -expires <YYYY-MM-DD>] <result>...
+//	expectations filters -file <file> [-o <file>]
+//
+// update applies fresh results: expectations of apps that now pass are
+// removed, and apps that fail without an expectation are reported, or added
+// when -bug and -expires are given. filters writes a Tradefed config with an
+// exclude filter per unexpired expectation, to be <include>d by the plan;
+// expired expectations are reported and left out, so that their apps run
+// again until the expectation is renewed or removed.
+//
+// An expectation file is JSON. add and update create it when it does not
+// exist, and take the plan name from its file name.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	generator           = "test/app_compat/csuite/tools/expectations"
+	excludeFilterOption = "compatibility:exclude-filter"
+	dateLayout          = "2006-01-02"
+)
+
+// now is a variable so that tests can fix the date.
+var now = time.Now
+
+type expectationFile struct {
+	Plan         string        `json:"plan"`
+	Expectations []expectation `json:"expectations"`
+}
+
+type expectation struct {
+	Package string `json:"package"`
+	Bug     string `json:"bug"`
+	// Expires is a date in the YYYY-MM-DD format. The expectation applies
+	// until the end of that day.
+	Expires string `json:"expires"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (e *expectation) expired() bool {
+	if _, err := time.Parse(dateLayout, e.Expires); err != nil {
+		return true
+	}
+	return now().Format(dateLayout) > e.Expires
+}
+
+func validate(e expectation) error {
+	if !packagelist.ValidPackageName(e.Package) {
+		return fmt.Errorf("invalid package name %q", e.Package)
+	}
+	if e.Bug == "" {
+		return fmt.Errorf("%s: a bug is required", e.Package)
+	}
+	if _, err := time.Parse(dateLayout, e.Expires); err != nil {
+		return fmt.Errorf("%s: invalid expiry date %q, want YYYY-MM-DD", e.Package, e.Expires)
+	}
+	return nil
+}
+
+func load(path string, create bool) (*expectationFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		plan := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		return &expectationFile{Plan: plan}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f expectationFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &f, nil
+}
+
+func (f *expectationFile) save(path string) error {
+	sort.Slice(f.Expectations, func(i, j int) bool { return f.Expectations[i].Package < f.Expectations[j].Package })
+	if f.Expectations == nil {
+		f.Expectations = []expectation{}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (f *expectationFile) find(pkg string) int {
+	for i, e := range f.Expectations {
+		if e.Package == pkg {
+			return i
+		}
+	}
+	return -1
+}
+
+// add adds e, replacing any expectation of the same package.
+func (f *expectationFile) add(e expectation) error {
+	if err := validate(e); err != nil {
+		return err
+	}
+	if i := f.find(e.Package); i >= 0 {
+		f.Expectations[i] = e
+		return nil
+	}
+	f.Expectations = append(f.Expectations, e)
+	return nil
+}
+
+func (f *expectationFile) remove(pkg string) bool {
+	i := f.find(pkg)
+	if i < 0 {
+		return false
+	}
+	f.Expectations = append(f.Expectations[:i], f.Expectations[i+1:]...)
+	return true
+}
+
+// update applies results to f. It returns the packages whose expectations
+// were removed because they passed, and the failing packages without an
+// expectation, which are added with template's bug and expiry date unless
+// template is nil.
+func (f *expectationFile) update(rows []result.Row, template *expectation) (fixed, unexpected []string, err error) {
+	passed := make(map[string]bool)
+	failed := make(map[string]bool)
+	for _, r := range rows {
+		// Rows of modules that are not generated app modules have no
+		// package.
+		if r.Package == "" {
+			continue
+		}
+		switch r.Outcome {
+		case result.Pass:
+			passed[r.Package] = true
+		case result.Fail, result.Crash:
+			failed[r.Package] = true
+		}
+	}
+
+	for pkg := range passed {
+		// An app that passed in one run but failed in another is flaky, not
+		// fixed.
+		if !failed[pkg] && f.remove(pkg) {
+			fixed = append(fixed, pkg)
+		}
+	}
+	for pkg := range failed {
+		if f.find(pkg) >= 0 {
+			continue
+		}
+		unexpected = append(unexpected, pkg)
+		if template != nil {
+			e := *template
+			e.Package = pkg
+			if err := f.add(e); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	sort.Strings(fixed)
+	sort.Strings(unexpected)
+	return fixed, unexpected, nil
+}
+
+// filters returns the exclude filters of the unexpired expectations, and
+// the expired expectations.
+func (f *expectationFile) filters() (*tfconfig.Configuration, []expectation) {
+	c := &tfconfig.Configuration{Description: fmt.Sprintf("Known failures of the %s plan", f.Plan)}
+	var expired []expectation
+	for _, e := range f.Expectations {
+		if e.expired() {
+			expired = append(expired, e)
+			continue
+		}
+		c.Options = append(c.Options, tfconfig.Option{Name: excludeFilterOption, Value: packagelist.ModuleName(e.Package)})
+	}
+	return c, expired
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  %[1]s add -file <file> -package <name> -bug <bug> -expires <YYYY-MM-DD> [-reason <text>]
+  %[1]s remove -file <file> -package <name>
+  %[1]s update -file <file> [-bug <bug> -expires <YYYY-MM-DD>] <result>...
+  %[1]s filters -file <file> [-o <file>]
+`, os.Args[0])
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	cmd.Usage = usage
+	file := cmd.String("file", "", "expectation file")
+	var e expectation
+	var out *string
+	switch os.Args[1] {
+	case "add", "update":
+		cmd.StringVar(&e.Bug, "bug", "", "bug tracking the failure")
+		cmd.StringVar(&e.Expires, "expires", "", "expiry date as YYYY-MM-DD")
+		if os.Args[1] == "add" {
+			cmd.StringVar(&e.Package, "package", "", "package name")
+			cmd.StringVar(&e.Reason, "reason", "", "why the app fails")
+		}
+	case "remove":
+		cmd.StringVar(&e.Package, "package", "", "package name")
+	case "filters":
+		out = cmd.String("o", "", "output file; defaults to stdout")
+	default:
+		usage()
+		os.Exit(2)
+	}
+	cmd.Parse(os.Args[2:])
+	if *file == "" || (os.Args[1] != "update" && cmd.NArg() != 0) || (os.Args[1] == "update" && cmd.NArg() == 0) {
+		usage()
+		os.Exit(2)
+	}
+
+	f, err := load(*file, os.Args[1] == "add" || os.Args[1] == "update")
+	if err != nil {
+		fail(err)
+	}
+	switch os.Args[1] {
+	case "add":
+		if err := f.add(e); err != nil {
+			fail(err)
+		}
+	case "remove":
+		if !f.remove(e.Package) {
+			fail(fmt.Errorf("%s has no expectation for %s", *file, e.Package))
+		}
+	case "update":
+		var template *expectation
+		if e.Bug != "" || e.Expires != "" {
+			template = &e
+		}
+		var rows []result.Row
+		for _, p := range cmd.Args() {
+			r, err := result.ParseFile(p)
+			if err != nil {
+				fail(err)
+			}
+			rows = append(rows, r.Rows()...)
+		}
+		fixed, unexpected, err := f.update(rows, template)
+		if err != nil {
+			fail(err)
+		}
+		for _, pkg := range fixed {
+			fmt.Printf("passed, expectation removed: %s\n", pkg)
+		}
+		for _, pkg := range unexpected {
+			if template != nil {
+				fmt.Printf("failed, expectation added: %s\n", pkg)
+			} else {
+				fmt.Printf("failed without expectation: %s\n", pkg)
+			}
+		}
+	case "filters":
+		c, expired := f.filters()
+		for _, e := range expired {
+			fmt.Fprintf(os.Stderr, "expired on %s, not excluded: %s (%s)\n", e.Expires, e.Package, e.Bug)
+		}
+		data := tfconfig.MarshalGenerated(c, generator)
+		if *out == "" {
+			if _, err := os.Stdout.Write(data); err != nil {
+				fail(err)
+			}
+			return
+		}
+		o, err := os.Create(*out)
+		if err != nil {
+			fail(err)
+		}
+		_, err = o.Write(data)
+		if closeErr := o.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fail(err)
+		}
+		return
+	}
+	if err := f.save(*file); err != nil {
+		fail(err)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func TestAdd_validatesAndReplaces(t *testing.T) {
+	f := &expectationFile{Plan: "launch"}
+
+	if err := f.add(expectation{Package: "com.example.a", Bug: "b/1", Expires: "2030-01-01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.add(expectation{Package: "com.example.a", Bug: "b/2", Expires: "2030-01-01"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Expectations) != 1 || f.Expectations[0].Bug != "b/2" {
+		t.Errorf("got %+v", f.Expectations)
+	}
+	for _, e := range []expectation{
+		{Package: "bad name", Bug: "b/1", Expires: "2030-01-01"},
+		{Package: "com.example.a", Expires: "2030-01-01"},
+		{Package: "com.example.a", Bug: "b/1", Expires: "01/01/2030"},
+	} {
+		if err := f.add(e); err == nil {
+			t.Errorf("add(%+v) succeeded, want error", e)
+		}
+	}
+}
+
+func TestUpdate_removesFixedAndAddsNewFailures(t *testing.T) {
+	f := &expectationFile{Expectations: []expectation{
+		{Package: "com.example.fixed", Bug: "b/1", Expires: "2030-01-01"},
+		{Package: "com.example.flaky", Bug: "b/2", Expires: "2030-01-01"},
+		{Package: "com.example.known", Bug: "b/3", Expires: "2030-01-01"},
+	}}
+	rows := []result.Row{
+		{Package: "com.example.fixed", Outcome: result.Pass},
+		{Package: "com.example.flaky", Outcome: result.Pass},
+		{Package: "com.example.flaky", Outcome: result.Crash},
+		{Package: "com.example.known", Outcome: result.Fail},
+		{Package: "com.example.new", Outcome: result.Crash},
+		{Package: "com.example.skipped", Outcome: result.Skip},
+	}
+
+	fixed, unexpected, err := f.update(rows, &expectation{Bug: "b/4", Expires: "2030-06-01"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fixed, []string{"com.example.fixed"}) || !reflect.DeepEqual(unexpected, []string{"com.example.new"}) {
+		t.Errorf("got fixed %v, unexpected %v", fixed, unexpected)
+	}
+	if i := f.find("com.example.new"); i < 0 || f.Expectations[i].Bug != "b/4" {
+		t.Errorf("got %+v, want new expectation", f.Expectations)
+	}
+	if f.find("com.example.fixed") >= 0 || f.find("com.example.flaky") < 0 {
+		t.Errorf("got %+v", f.Expectations)
+	}
+}
+
+func TestUpdate_noTemplate_onlyReports(t *testing.T) {
+	f := &expectationFile{}
+
+	_, unexpected, err := f.update([]result.Row{{Package: "com.example.new", Outcome: result.Fail}}, nil)
+
+	if err != nil || len(unexpected) != 1 || len(f.Expectations) != 0 {
+		t.Errorf("got %v, %v, %+v", unexpected, err, f.Expectations)
+	}
+}
+
+func TestUpdate_skipsRowsWithoutPackage(t *testing.T) {
+	f := &expectationFile{}
+	rows := []result.Row{
+		{Module: "CtsOtherModule", Outcome: result.Fail},
+		{Package: "com.example.new", Outcome: result.Fail},
+	}
+
+	_, unexpected, err := f.update(rows, &expectation{Bug: "b/1", Expires: "2030-01-01"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unexpected, []string{"com.example.new"}) || len(f.Expectations) != 1 {
+		t.Errorf("got %v, %+v", unexpected, f.Expectations)
+	}
+}
+
+func TestFilters_leavesOutExpiredExpectations(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = time.Now })
+	f := &expectationFile{Plan: "launch", Expectations: []expectation{
+		{Package: "com.example.expired", Bug: "b/1", Expires: "2026-10-15"},
+		{Package: "com.example.today", Bug: "b/2", Expires: "2026-10-16"},
+	}}
+
+	c, expired := f.filters()
+
+	if got := tfconfig.OptionValues(c.Options, excludeFilterOption); !reflect.DeepEqual(got, []string{"csuite_com.example.today"}) {
+		t.Errorf("got filters %v", got)
+	}
+	if len(expired) != 1 || expired[0].Package != "com.example.expired" {
+		t.Errorf("got expired %+v", expired)
+	}
+	if c.Description != "Known failures of the launch plan" {
+		t.Errorf("got description %q", c.Description)
+	}
+}
+
+func TestLoadAndSave_roundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "launch.json")
+	f, err := load(path, true)
+	if err != nil || f.Plan != "launch" {
+		t.Fatalf("load() = %+v, %v", f, err)
+	}
+	f.Expectations = []expectation{
+		{Package: "com.example.b", Bug: "b/2", Expires: "2030-01-01"},
+		{Package: "com.example.a", Bug: "b/1", Expires: "2030-01-01", Reason: "crashes on launch"},
+	}
+
+	if err := f.save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := load(path, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Plan != "launch" || len(got.Expectations) != 2 || got.Expectations[0].Package != "com.example.a" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestLoad_missingFile_failsUnlessCreating(t *testing.T) {
+	if _, err := load(filepath.Join(t.TempDir(), "missing.json"), false); err == nil {
+		t.Error("load() succeeded, want error")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// flake_analyzer compares several runs of the same plan and classifies each
+// app as stable-pass, stable-fail or flaky, and suggests the exclude filters
+// that keep flaky apps out of the plan until they are fixed.
+//
+// Usage:
+//
+//	flake_analyzer [-json] [-filters <file>] [-exclude_stable_fail] <result>...
+//
+// Each result is a test_result.xml or a results directory. Apps are
+// classified per ABI. An app is flaky when it passed in some runs and failed
+// in others; skipped tests are not counted. With -filters, the suggested
+// exclude filters, one per app and ABI, are written as a Tradefed config to
+// be <include>d by the plan. Stable failures are only excluded with
+// -exclude_stable_fail, as they are usually real incompatibilities rather
+// than noise.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	generator           = "test/app_compat/csuite/tools/flake_analyzer"
+	excludeFilterOption = "compatibility:exclude-filter"
+)
+
+// Classes of a verdict.
+const (
+	stablePass = "stable-pass"
+	stableFail = "stable-fail"
+	flaky      = "flaky"
+)
+
+type verdict struct {
+	Package string `json:"package"`
+	Module  string `json:"module"`
+	ABI     string `json:"abi"`
+	Class   string `json:"class"`
+	Passed  int    `json:"passed"`
+	Runs    int    `json:"runs"`
+}
+
+// analyze classifies every package found in runs on each ABI it was tested
+// on, each run being the rows of one result.
+func analyze(runs [][]result.Row) []verdict {
+	type key struct{ abi, pkg string }
+	byKey := make(map[key]*verdict)
+	for _, rows := range runs {
+		for _, r := range rows {
+			if r.Outcome == result.Skip {
+				continue
+			}
+			k := key{r.ABI, r.Package}
+			v, ok := byKey[k]
+			if !ok {
+				v = &verdict{Package: r.Package, Module: r.Module, ABI: r.ABI}
+				byKey[k] = v
+			}
+			v.Runs++
+			if r.Outcome == result.Pass {
+				v.Passed++
+			}
+		}
+	}
+
+	var verdicts []verdict
+	for _, v := range byKey {
+		switch v.Passed {
+		case v.Runs:
+			v.Class = stablePass
+		case 0:
+			v.Class = stableFail
+		default:
+			v.Class = flaky
+		}
+		verdicts = append(verdicts, *v)
+	}
+	sort.Slice(verdicts, func(i, j int) bool {
+		if verdicts[i].Class != verdicts[j].Class {
+			return classOrder(verdicts[i].Class) < classOrder(verdicts[j].Class)
+		}
+		if verdicts[i].Package != verdicts[j].Package {
+			return verdicts[i].Package < verdicts[j].Package
+		}
+		return verdicts[i].ABI < verdicts[j].ABI
+	})
+	return verdicts
+}
+
+func classOrder(class string) int {
+	switch class {
+	case flaky:
+		return 0
+	case stableFail:
+		return 1
+	}
+	return 2
+}
+
+// excludeFilters returns a config excluding the modules of the flaky apps,
+// and of the stable failures if excludeStableFail is set, on the ABIs they
+// had those results on.
+func excludeFilters(verdicts []verdict, excludeStableFail bool) *tfconfig.Configuration {
+	c := &tfconfig.Configuration{}
+	excluded := make(map[string]bool)
+	for _, v := range verdicts {
+		if v.Class == flaky || (excludeStableFail && v.Class == stableFail) {
+			c.Options = append(c.Options, tfconfig.Option{Name: excludeFilterOption,
+				Value: strings.TrimSpace(v.ABI + " " + v.Module)})
+			excluded[v.Class] = true
+		}
+	}
+	switch {
+	case excluded[flaky] && excluded[stableFail]:
+		c.Description = "Apps excluded for flaky or failing results"
+	case excluded[stableFail]:
+		c.Description = "Apps excluded for failing results"
+	default:
+		c.Description = "Apps excluded for flaky results"
+	}
+	return c
+}
+
+// checkSamePlan returns an error if the results are from different plans.
+func checkSamePlan(paths []string, results []*result.Result) error {
+	for i := 1; i < len(results); i++ {
+		if results[i].SuitePlan != results[0].SuitePlan {
+			return fmt.Errorf("%s is a run of plan %q but %s is a run of plan %q",
+				paths[i], results[i].SuitePlan, paths[0], results[0].SuitePlan)
+		}
+	}
+	return nil
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the verdicts as JSON")
+	filters := flag.String("filters", "", "write the suggested exclude filters to this file")
+	excludeStableFail := flag.Bool("exclude_stable_fail", false, "also suggest excluding apps that failed in every run")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-json] [-filters <file>] [-exclude_stable_fail] <result>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var results []*result.Result
+	var runs [][]result.Row
+	for _, p := range flag.Args() {
+		r, err := result.ParseFile(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		results = append(results, r)
+		runs = append(runs, r.Rows())
+	}
+	if err := checkSamePlan(flag.Args(), results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	verdicts := analyze(runs)
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if verdicts == nil {
+			verdicts = []verdict{}
+		}
+		e.Encode(verdicts)
+	} else {
+		for _, v := range verdicts {
+			fmt.Printf("%-11s %s (%d/%d passed)\n", v.Class, strings.TrimSpace(v.ABI+" "+v.Package), v.Passed, v.Runs)
+		}
+	}
+
+	if *filters != "" {
+		data := tfconfig.MarshalGenerated(excludeFilters(verdicts, *excludeStableFail), generator)
+		if err := os.WriteFile(*filters, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+}
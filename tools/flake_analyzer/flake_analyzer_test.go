@@ -0,0 +1,106 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func TestAnalyze_classifiesPackages(t *testing.T) {
+	runs := [][]result.Row{
+		{row("com.example.pass", result.Pass), row("com.example.fail", result.Crash), row("com.example.flaky", result.Pass)},
+		{row("com.example.pass", result.Pass), row("com.example.fail", result.Fail), row("com.example.flaky", result.Crash)},
+		{row("com.example.pass", result.Skip), row("com.example.skipped", result.Skip)},
+	}
+
+	got := analyze(runs)
+
+	want := []verdict{
+		{Package: "com.example.flaky", Module: "csuite_com.example.flaky", Class: flaky, Passed: 1, Runs: 2},
+		{Package: "com.example.fail", Module: "csuite_com.example.fail", Class: stableFail, Passed: 0, Runs: 2},
+		{Package: "com.example.pass", Module: "csuite_com.example.pass", Class: stablePass, Passed: 2, Runs: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestAnalyze_classifiesEachABI(t *testing.T) {
+	arm64 := row("com.example.a", result.Pass)
+	arm64.ABI = "arm64-v8a"
+	arm := row("com.example.a", result.Fail)
+	arm.ABI = "armeabi-v7a"
+	runs := [][]result.Row{{arm64, arm}, {arm64, arm}}
+
+	got := analyze(runs)
+
+	want := []verdict{
+		{Package: "com.example.a", Module: "csuite_com.example.a", ABI: "armeabi-v7a", Class: stableFail, Passed: 0, Runs: 2},
+		{Package: "com.example.a", Module: "csuite_com.example.a", ABI: "arm64-v8a", Class: stablePass, Passed: 2, Runs: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestExcludeFilters(t *testing.T) {
+	verdicts := []verdict{
+		{Module: "csuite_com.example.flaky", Class: flaky},
+		{Module: "csuite_com.example.fail", Class: stableFail},
+		{Module: "csuite_com.example.pass", Class: stablePass},
+	}
+
+	flakyOnly := tfconfig.OptionValues(excludeFilters(verdicts, false).Options, excludeFilterOption)
+	withFailures := tfconfig.OptionValues(excludeFilters(verdicts, true).Options, excludeFilterOption)
+
+	if want := []string{"csuite_com.example.flaky"}; !reflect.DeepEqual(flakyOnly, want) {
+		t.Errorf("got %v, want %v", flakyOnly, want)
+	}
+	if want := []string{"csuite_com.example.flaky", "csuite_com.example.fail"}; !reflect.DeepEqual(withFailures, want) {
+		t.Errorf("got %v, want %v", withFailures, want)
+	}
+}
+
+func TestExcludeFilters_prefixesABIAndDescribesExclusions(t *testing.T) {
+	verdicts := []verdict{{Module: "csuite_com.example.fail", ABI: "arm64-v8a", Class: stableFail}}
+
+	c := excludeFilters(verdicts, true)
+
+	if want := []string{"arm64-v8a csuite_com.example.fail"}; !reflect.DeepEqual(tfconfig.OptionValues(c.Options, excludeFilterOption), want) {
+		t.Errorf("got %v, want %v", c.Options, want)
+	}
+	if want := "Apps excluded for failing results"; c.Description != want {
+		t.Errorf("got description %q, want %q", c.Description, want)
+	}
+}
+
+func TestCheckSamePlan(t *testing.T) {
+	paths := []string{"a", "b"}
+
+	if err := checkSamePlan(paths, []*result.Result{{SuitePlan: "launch"}, {SuitePlan: "launch"}}); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if err := checkSamePlan(paths, []*result.Result{{SuitePlan: "launch"}, {SuitePlan: "crawl"}}); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func row(pkg, outcome string) result.Row {
+	return result.Row{Package: pkg, Module: "csuite_" + pkg, Outcome: outcome}
+}
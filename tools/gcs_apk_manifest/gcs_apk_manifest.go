@@ -0,0 +1,245 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// gcs_apk_manifest lists the APKs staged under a GCS prefix and writes a
+// manifest of them, so that bucket contents and build configs can be kept in
+// sync.
+//
+// Usage:
+//
+//	gcs_apk_manifest [-format json|csv] [-package_list <file>] gs://<bucket>/<prefix>
+//
+// The prefix is laid out the way AppSetupPreparer expects its gcs-apk-dir:
+// one directory per package, holding the APK or split APKs of that package
+// at any depth, as AppSetupPreparer installs every APK it finds below the
+// package directory.
+// Version names and codes are taken from the versionName and versionCode
+// custom metadata of the objects when set. The access token is read from
+// the GCS_ACCESS_TOKEN environment variable, e.g. the output of
+// "gcloud auth print-access-token".
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+)
+
+const (
+	defaultEndpoint = "https://storage.googleapis.com/storage/v1"
+	tokenEnv        = "GCS_ACCESS_TOKEN"
+	apkExtension    = ".apk"
+)
+
+// ApkEntry describes one staged APK.
+type ApkEntry struct {
+	Package     string `json:"package"`
+	VersionName string `json:"version_name,omitempty"`
+	VersionCode string `json:"version_code,omitempty"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+}
+
+type object struct {
+	Name     string            `json:"name"`
+	Size     string            `json:"size"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type listResponse struct {
+	Items         []object `json:"items"`
+	NextPageToken string   `json:"nextPageToken"`
+}
+
+type lister struct {
+	client   *http.Client
+	endpoint string
+	token    string
+}
+
+// list returns all objects under prefix in bucket, following pagination.
+func (l *lister) list(bucket, prefix string) ([]object, error) {
+	var objects []object
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		u := fmt.Sprintf("%s/b/%s/o?%s", l.endpoint, url.PathEscape(bucket), query.Encode())
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if l.token != "" {
+			req.Header.Set("Authorization", "Bearer "+l.token)
+		}
+		resp, err := l.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page listResponse
+		err = decodeResponse(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, page.Items...)
+		if page.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("listing objects failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseGCSPath splits gs://bucket/prefix into the bucket and a prefix that
+// ends with a slash, or is empty.
+func parseGCSPath(p string) (string, string, error) {
+	if !strings.HasPrefix(p, "gs://") {
+		return "", "", fmt.Errorf("%q is not a gs:// path", p)
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(p, "gs://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("%q has no bucket", p)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// manifest returns the APKs among objects that are in a package directory
+// under prefix, at any depth, sorted by package and path. Objects elsewhere
+// are ignored.
+func manifest(bucket, prefix string, objects []object) []ApkEntry {
+	var entries []ApkEntry
+	for _, o := range objects {
+		rel := strings.TrimPrefix(o.Name, prefix)
+		pkg, file, ok := strings.Cut(rel, "/")
+		if !ok || pkg == "" || !strings.HasSuffix(file, apkExtension) {
+			continue
+		}
+		size, _ := strconv.ParseInt(o.Size, 10, 64)
+		entries = append(entries, ApkEntry{
+			Package:     pkg,
+			VersionName: o.Metadata["versionName"],
+			VersionCode: o.Metadata["versionCode"],
+			Path:        "gs://" + bucket + "/" + o.Name,
+			Size:        size,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Package != entries[j].Package {
+			return entries[i].Package < entries[j].Package
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+func packageNames(entries []ApkEntry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Package)
+	}
+	return packagelist.Dedup(names)
+}
+
+func writeManifest(w io.Writer, format string, entries []ApkEntry) error {
+	switch format {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		if entries == nil {
+			entries = []ApkEntry{}
+		}
+		return e.Encode(entries)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"package", "version_name", "version_code", "path", "size"})
+		for _, e := range entries {
+			cw.Write([]string{e.Package, e.VersionName, e.VersionCode, e.Path, strconv.FormatInt(e.Size, 10)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	format := flag.String("format", "json", "manifest format: json or csv")
+	packageList := flag.String("package_list", "", "also write the package names to this file")
+	endpoint := flag.String("endpoint", defaultEndpoint, "GCS JSON API endpoint")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-format json|csv] [-package_list <file>] gs://<bucket>/<prefix>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "json" && *format != "csv") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bucket, prefix, err := parseGCSPath(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	l := &lister{client: http.DefaultClient, endpoint: *endpoint, token: os.Getenv(tokenEnv)}
+	objects, err := l.list(bucket, prefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	entries := manifest(bucket, prefix, objects)
+	if err := writeManifest(os.Stdout, *format, entries); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *packageList != "" {
+		f, err := os.Create(*packageList)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = packagelist.Write(f, packageNames(entries))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseGCSPath(t *testing.T) {
+	for p, want := range map[string][2]string{
+		"gs://bucket":              {"bucket", ""},
+		"gs://bucket/apks":         {"bucket", "apks/"},
+		"gs://bucket/apks/2020/":   {"bucket", "apks/2020/"},
+		"gs://bucket/apks/2020/xx": {"bucket", "apks/2020/xx/"},
+	} {
+		bucket, prefix, err := parseGCSPath(p)
+		if err != nil || bucket != want[0] || prefix != want[1] {
+			t.Errorf("parseGCSPath(%q) = %q, %q, %v, want %q, %q", p, bucket, prefix, err, want[0], want[1])
+		}
+	}
+}
+
+func TestParseGCSPath_invalidPath_returnsError(t *testing.T) {
+	for _, p := range []string{"/local/dir", "gs://", "bucket/apks"} {
+		if _, _, err := parseGCSPath(p); err == nil {
+			t.Errorf("parseGCSPath(%q) succeeded, want error", p)
+		}
+	}
+}
+
+func TestList_followsPagination(t *testing.T) {
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/b/bucket/o" || r.URL.Query().Get("prefix") != "apks/" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		page := listResponse{Items: []object{{Name: "apks/com.example.a/base.apk"}}, NextPageToken: "next"}
+		if r.URL.Query().Get("pageToken") == "next" {
+			page = listResponse{Items: []object{{Name: "apks/com.example.b/base.apk"}}}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+	l := &lister{client: server.Client(), endpoint: server.URL, token: "secret"}
+
+	objects, err := l.list("bucket", "apks/")
+
+	if err != nil {
+		t.Fatalf("list() failed: %v", err)
+	}
+	if len(objects) != 2 || objects[1].Name != "apks/com.example.b/base.apk" {
+		t.Errorf("got objects %v", objects)
+	}
+	if want := []string{"Bearer secret", "Bearer secret"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("got authorization headers %v, want %v", tokens, want)
+	}
+}
+
+func TestList_errorStatus_returnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+	l := &lister{client: server.Client(), endpoint: server.URL}
+
+	if _, err := l.list("bucket", ""); err == nil {
+		t.Error("list() succeeded, want error")
+	}
+}
+
+func TestManifest_keepsApksUnderPackageDirectories(t *testing.T) {
+	objects := []object{
+		{Name: "apks/com.example.b/base.apk", Size: "20"},
+		{Name: "apks/com.example.a/split_config.arm64.apk", Size: "5"},
+		{Name: "apks/com.example.a/base.apk", Size: "10",
+			Metadata: map[string]string{"versionName": "1.2", "versionCode": "12"}},
+		{Name: "apks/com.example.a/notes.txt"},
+		{Name: "apks/stray.apk"},
+		{Name: "apks/com.example.c/nested/base.apk"},
+	}
+
+	got := manifest("bucket", "apks/", objects)
+
+	want := []ApkEntry{
+		{Package: "com.example.a", VersionName: "1.2", VersionCode: "12", Path: "gs://bucket/apks/com.example.a/base.apk", Size: 10},
+		{Package: "com.example.a", Path: "gs://bucket/apks/com.example.a/split_config.arm64.apk", Size: 5},
+		{Package: "com.example.b", Path: "gs://bucket/apks/com.example.b/base.apk", Size: 20},
+		{Package: "com.example.c", Path: "gs://bucket/apks/com.example.c/nested/base.apk"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if names := packageNames(got); !reflect.DeepEqual(names, []string{"com.example.a", "com.example.b", "com.example.c"}) {
+		t.Errorf("got package names %v", names)
+	}
+}
+
+func TestWriteManifest_csv(t *testing.T) {
+	var buf bytes.Buffer
+	entries := []ApkEntry{{Package: "com.example.a", VersionCode: "12", Path: "gs://b/a.apk", Size: 10}}
+
+	if err := writeManifest(&buf, "csv", entries); err != nil {
+		t.Fatalf("writeManifest() failed: %v", err)
+	}
+
+	want := "package,version_name,version_code,path,size\ncom.example.a,,12,gs://b/a.apk,10\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteManifest_emptyJson_writesEmptyArray(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := writeManifest(&buf, "json", nil); err != nil {
+		t.Fatalf("writeManifest() failed: %v", err)
+	}
+
+	if buf.String() != "[]\n" {
+		t.Errorf("got %q, want []", buf.String())
+	}
+}
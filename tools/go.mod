@@ -0,0 +1,3 @@
+module android/test/app_compat/csuite/tools
+
+go 1.18
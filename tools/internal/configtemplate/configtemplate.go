@@ -0,0 +1,90 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configtemplate expands Tradefed config templates. Templates are
+// configs with placeholders such as {package_name}, in the syntax of the
+// Python str.format templates used by generate_module.py: "{{" and "}}" stand
+// for literal braces.
+package configtemplate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{\{|\}\}|\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	namePattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Placeholders returns the names of the placeholders in template, sorted and
+// without duplicates.
+func Placeholders(template []byte) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range placeholderPattern.FindAllSubmatch(template, -1) {
+		if name := string(m[1]); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Expand replaces the placeholders in template with the values of vars,
+// escaped for XML, and checks that the result is a valid config. It fails if
+// any placeholder has no value.
+func Expand(template []byte, vars map[string]string) ([]byte, error) {
+	var missing []string
+	for _, name := range Placeholders(template) {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no value for placeholders: %s", strings.Join(missing, ", "))
+	}
+
+	out := placeholderPattern.ReplaceAllFunc(template, func(m []byte) []byte {
+		switch string(m) {
+		case "{{":
+			return []byte("{")
+		case "}}":
+			return []byte("}")
+		}
+		return []byte(tfconfig.EscapeAttr(vars[string(m[1:len(m)-1])]))
+	})
+	if _, err := tfconfig.Parse(out); err != nil {
+		return nil, fmt.Errorf("expanded config is invalid: %v", err)
+	}
+	return out, nil
+}
+
+// ParseVar splits a name=value assignment, as given on the command line.
+func ParseVar(s string) (name, value string, err error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid variable %q, want name=value", s)
+	}
+	name, value = s[:i], s[i+1:]
+	if !namePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid variable name %q", name)
+	}
+	return name, value, nil
+}
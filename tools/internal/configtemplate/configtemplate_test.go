@@ -0,0 +1,84 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configtemplate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const template = `<configuration description="{plan} plan {{draft}}">
+    <include name="csuite-base" />
+    <option name="compatibility:include-filter" value="{module}" />
+    <option name="plan" value="{plan}" />
+</configuration>
+`
+
+func TestPlaceholders(t *testing.T) {
+	if got, want := Placeholders([]byte(template)), []string{"module", "plan"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExpand_replacesPlaceholdersAndBraces(t *testing.T) {
+	got, err := Expand([]byte(template), map[string]string{"plan": "top & new", "module": "csuite_com.example.a"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<configuration description="top &amp; new plan {draft}">
+    <include name="csuite-base" />
+    <option name="compatibility:include-filter" value="csuite_com.example.a" />
+    <option name="plan" value="top &amp; new" />
+</configuration>
+`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExpand_missingValues_returnsErrorNamingThem(t *testing.T) {
+	_, err := Expand([]byte(template), map[string]string{})
+
+	if err == nil || !strings.Contains(err.Error(), "module, plan") {
+		t.Errorf("got %v, want error naming module and plan", err)
+	}
+}
+
+func TestExpand_markupInValue_isEscaped(t *testing.T) {
+	got, err := Expand([]byte(`<configuration>{body}</configuration>`), map[string]string{"body": "<test>"})
+
+	if err != nil || string(got) != `<configuration>&lt;test&gt;</configuration>` {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestExpand_invalidResult_returnsError(t *testing.T) {
+	if _, err := Expand([]byte(`<configuration><{element} /></configuration>`), map[string]string{"element": "1bad"}); err == nil {
+		t.Error("Expand() succeeded, want error")
+	}
+}
+
+func TestParseVar(t *testing.T) {
+	if name, value, err := ParseVar("package=com.example.a=b"); err != nil || name != "package" || value != "com.example.a=b" {
+		t.Errorf("got %q, %q, %v", name, value, err)
+	}
+	for _, s := range []string{"", "=x", "a b=x", "noequals"} {
+		if _, _, err := ParseVar(s); err == nil {
+			t.Errorf("ParseVar(%q) succeeded, want error", s)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flagutil holds flag types shared by the tools.
+package flagutil
+
+import "strings"
+
+// StringList is a flag that may be repeated, collecting every value in
+// order.
+type StringList []string
+
+// String returns the values joined by commas.
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends s to the list.
+func (l *StringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flagutil
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestStringList_collectsRepeatedFlags(t *testing.T) {
+	var l StringList
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&l, "with", "")
+
+	if err := fs.Parse([]string{"-with", "a", "-with", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (StringList{"a", "b"}); !reflect.DeepEqual(l, want) {
+		t.Errorf("got %v, want %v", l, want)
+	}
+	if got := l.String(); got != "a,b" {
+		t.Errorf("got String() %q, want a,b", got)
+	}
+}
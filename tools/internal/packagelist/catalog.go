@@ -0,0 +1,168 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagelist
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PackageColumns are the CSV columns and JSON fields that commonly hold the
+// package name in app catalogs, in order of preference.
+var PackageColumns = []string{"package", "package_name", "packageName", "app_package"}
+
+// rankingColumns is the number of columns in the ranking files read by
+// PublicApkUtil, where the package name is the second column.
+const (
+	rankingColumns       = 5
+	rankingPackageColumn = 1
+)
+
+// Record is an app read from a catalog exported from a spreadsheet or
+// another tool.
+type Record struct {
+	// Location is the file and line or index of the record, for error
+	// messages.
+	Location string
+	// Package is the package name, which may be invalid.
+	Package string
+	// Fields holds the string and number values of the record by column or
+	// field name.
+	Fields map[string]string
+}
+
+// ReadCSVCatalog reads a CSV catalog with a header row, named name in error
+// messages. The package name is in column or, if column is empty, in the
+// first of PackageColumns in the header; files with the rank, package,
+// version string, version code and file name columns read by PublicApkUtil
+// are also recognized by their shape.
+func ReadCSVCatalog(name string, r io.Reader, column string) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	index := findColumn(header, column)
+	if index < 0 && column == "" && isRankingFile(rows) {
+		index = rankingPackageColumn
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("%s: no package column in header %v", name, header)
+	}
+	var records []Record
+	for i, row := range rows[1:] {
+		location := fmt.Sprintf("%s:%d", name, i+2)
+		if index >= len(row) {
+			return nil, fmt.Errorf("%s: missing package column", location)
+		}
+		fields := make(map[string]string)
+		for j, value := range row {
+			if j < len(header) {
+				fields[strings.TrimSpace(header[j])] = strings.TrimSpace(value)
+			}
+		}
+		records = append(records, Record{location, strings.TrimSpace(row[index]), fields})
+	}
+	return records, nil
+}
+
+func findColumn(header []string, column string) int {
+	candidates := PackageColumns
+	if column != "" {
+		candidates = []string{column}
+	}
+	for _, candidate := range candidates {
+		for i, name := range header {
+			if strings.TrimSpace(name) == candidate {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isRankingFile(rows [][]string) bool {
+	if len(rows) < 2 {
+		return false
+	}
+	for _, row := range rows[1:] {
+		if len(row) != rankingColumns {
+			return false
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(row[0])); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadJSONCatalog reads a JSON catalog, named name in error messages. The
+// catalog is an array of package names, or of objects with the package name
+// in the column field or, if column is empty, in the first of
+// PackageColumns the object has.
+func ReadJSONCatalog(name string, r io.Reader, column string) ([]Record, error) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, fmt.Errorf("%s: expected a JSON array: %v", name, err)
+	}
+	candidates := PackageColumns
+	if column != "" {
+		candidates = []string{column}
+	}
+	var records []Record
+	for i, item := range items {
+		location := fmt.Sprintf("%s[%d]", name, i)
+		var pkg string
+		if err := json.Unmarshal(item, &pkg); err == nil {
+			records = append(records, Record{location, strings.TrimSpace(pkg), map[string]string{}})
+			continue
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal(item, &object); err != nil {
+			return nil, fmt.Errorf("%s: expected a string or an object", location)
+		}
+		fields := make(map[string]string)
+		for k, v := range object {
+			switch v := v.(type) {
+			case string:
+				fields[k] = v
+			case float64:
+				fields[k] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		found := false
+		for _, candidate := range candidates {
+			if _, ok := object[candidate].(string); ok {
+				pkg, found = fields[candidate], true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no package field", location)
+		}
+		records = append(records, Record{location, strings.TrimSpace(pkg), fields})
+	}
+	return records, nil
+}
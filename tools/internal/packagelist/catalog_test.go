@@ -0,0 +1,118 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagelist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSVCatalog_withKnownHeader_readsPackageColumn(t *testing.T) {
+	data := "title,package_name\nApp A,com.example.a\nApp B, com.example.b\n"
+
+	records, err := ReadCSVCatalog("apps.csv", strings.NewReader(data), "")
+
+	if err != nil {
+		t.Fatalf("ReadCSVCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a", "com.example.b")
+	if records[1].Location != "apps.csv:3" {
+		t.Errorf("got location %q, want apps.csv:3", records[1].Location)
+	}
+}
+
+func TestReadCSVCatalog_withColumnFlag_readsNamedColumn(t *testing.T) {
+	data := "id,pkg\n1,com.example.a\n"
+
+	records, err := ReadCSVCatalog("apps.csv", strings.NewReader(data), "pkg")
+
+	if err != nil {
+		t.Fatalf("ReadCSVCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a")
+}
+
+func TestReadCSVCatalog_rankingFile_readsSecondColumn(t *testing.T) {
+	data := "rank,pkg,version,code,file\n1,com.example.a,1.0,10,a.apk\n2,com.example.b,2.0,20,b.apk\n"
+
+	records, err := ReadCSVCatalog("flavor_ranking.csv", strings.NewReader(data), "")
+
+	if err != nil {
+		t.Fatalf("ReadCSVCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a", "com.example.b")
+}
+
+func TestReadCSVCatalog_withoutPackageColumn_returnsError(t *testing.T) {
+	_, err := ReadCSVCatalog("apps.csv", strings.NewReader("title,rating\nApp A,5\n"), "")
+
+	if err == nil {
+		t.Error("ReadCSVCatalog() succeeded, want error")
+	}
+}
+
+func TestReadJSONCatalog_strings_readsNames(t *testing.T) {
+	records, err := ReadJSONCatalog("apps.json", strings.NewReader(`["com.example.a", "com.example.b"]`), "")
+
+	if err != nil {
+		t.Fatalf("ReadJSONCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a", "com.example.b")
+}
+
+func TestReadJSONCatalog_objects_readsPackageField(t *testing.T) {
+	data := `[{"packageName": "com.example.a", "rank": 1}, {"packageName": "com.example.b"}]`
+
+	records, err := ReadJSONCatalog("apps.json", strings.NewReader(data), "")
+
+	if err != nil {
+		t.Fatalf("ReadJSONCatalog() failed: %v", err)
+	}
+	assertNames(t, records, "com.example.a", "com.example.b")
+}
+
+func TestReadJSONCatalog_objectWithoutField_returnsError(t *testing.T) {
+	_, err := ReadJSONCatalog("apps.json", strings.NewReader(`[{"name": "App"}]`), "")
+
+	if err == nil {
+		t.Error("ReadJSONCatalog() succeeded, want error")
+	}
+}
+
+func TestReadJSONCatalog_keepsStringAndNumberFields(t *testing.T) {
+	data := `[{"package": "com.example.a", "rank": 2, "category": "Social", "free": true}]`
+
+	records, err := ReadJSONCatalog("apps.json", strings.NewReader(data), "")
+
+	if err != nil {
+		t.Fatalf("ReadJSONCatalog() failed: %v", err)
+	}
+	want := map[string]string{"package": "com.example.a", "rank": "2", "category": "Social"}
+	if len(records) != 1 || !reflect.DeepEqual(records[0].Fields, want) {
+		t.Errorf("got %+v, want fields %v", records, want)
+	}
+}
+
+func assertNames(t *testing.T, records []Record, names ...string) {
+	t.Helper()
+	var got []string
+	for _, r := range records {
+		got = append(got, r.Package)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("got names %v, want %v", got, names)
+	}
+}
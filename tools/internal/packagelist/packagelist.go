@@ -0,0 +1,107 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package packagelist reads and writes package list files, the format taken
+// by tools/script/generate_module.py: one package name per line, with blank
+// lines and surrounding whitespace ignored. It also reads the CSV and JSON
+// app catalogs that package lists are made from.
+package packagelist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ModulePrefix is prepended to package names to form the names of the
+// generated modules, e.g. csuite_com.example.app.
+const ModulePrefix = "csuite_"
+
+var packageNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+
+// ValidPackageName reports whether name is a valid Android application
+// package name: at least two dot-separated segments, each starting with a
+// letter and containing only letters, digits and underscores.
+func ValidPackageName(name string) bool {
+	return packageNamePattern.MatchString(name)
+}
+
+// ModuleName returns the name of the module generated for a package.
+func ModuleName(packageName string) string {
+	return ModulePrefix + packageName
+}
+
+// PackageName returns the package name a generated module tests, or false if
+// module is not a generated module name.
+func PackageName(module string) (string, bool) {
+	if !strings.HasPrefix(module, ModulePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(module, ModulePrefix), true
+}
+
+// Dedup returns names with empty and repeated names removed, keeping the
+// first occurrence of each name.
+func Dedup(names []string) []string {
+	seen := make(map[string]bool)
+	var deduped []string
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		deduped = append(deduped, name)
+	}
+	return deduped
+}
+
+// Read returns the package names in r, in file order and without duplicates.
+func Read(r io.Reader) ([]string, error) {
+	var names []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		names = append(names, strings.TrimSpace(s.Text()))
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return Dedup(names), nil
+}
+
+// ReadFile returns the package names in the file at path.
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return names, nil
+}
+
+// Write writes names to w, one per line.
+func Write(w io.Writer, names []string) error {
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
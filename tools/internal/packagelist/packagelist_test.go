@@ -0,0 +1,91 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packagelist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidPackageName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"com.example.app":    true,
+		"com.example_2.App3": true,
+		"a.b":                true,
+		"example":            false,
+		"com..example":       false,
+		"com.2example":       false,
+		"com.example-app":    false,
+		".com.example":       false,
+		"com.example.":       false,
+		"":                   false,
+	} {
+		if got := ValidPackageName(name); got != want {
+			t.Errorf("ValidPackageName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestModuleName_roundTripsThroughPackageName(t *testing.T) {
+	module := ModuleName("com.example.app")
+
+	got, ok := PackageName(module)
+
+	if module != "csuite_com.example.app" || !ok || got != "com.example.app" {
+		t.Errorf("got module %q and package %q, %v", module, got, ok)
+	}
+}
+
+func TestPackageName_notAGeneratedModule_returnsFalse(t *testing.T) {
+	if _, ok := PackageName("csuite-harness-tests"); ok {
+		t.Error("PackageName() returned true for a non-generated module")
+	}
+}
+
+func TestRead_ignoresBlankLinesAndWhitespace(t *testing.T) {
+	got, err := Read(strings.NewReader("\n\n  com.example.a  \n\ncom.example.b\n"))
+
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if want := []string{"com.example.a", "com.example.b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRead_duplicatePackageNames_keepsFirst(t *testing.T) {
+	got, err := Read(strings.NewReader("com.example.b\ncom.example.a\ncom.example.b\n"))
+
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if want := []string{"com.example.b", "com.example.a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWrite_writesOneNamePerLine(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := Write(&buf, []string{"com.example.a", "com.example.b"}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	if want := "com.example.a\ncom.example.b\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
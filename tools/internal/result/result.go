@@ -0,0 +1,214 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package result reads the test_result.xml files that C-Suite writes to its
+// results directory, and turns them into one row per tested app.
+package result
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+)
+
+// FileName is the name of the result file in a results directory.
+const FileName = "test_result.xml"
+
+// Outcomes of a Row.
+const (
+	Pass  = "pass"
+	Fail  = "fail"
+	Crash = "crash"
+	Skip  = "skip"
+)
+
+// Statuses reported by the harness, the prefix of failure messages. See
+// CompatibilityTestResult.
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+	StatusFailure = "failure"
+)
+
+// Result is a parsed test_result.xml.
+type Result struct {
+	XMLName   xml.Name `xml:"Result"`
+	SuiteName string   `xml:"suite_name,attr"`
+	SuitePlan string   `xml:"suite_plan,attr"`
+	// Start and End are in milliseconds since the epoch.
+	Start   int64    `xml:"start,attr"`
+	End     int64    `xml:"end,attr"`
+	Build   Build    `xml:"Build"`
+	Modules []Module `xml:"Module"`
+}
+
+// Build describes the device the suite ran on.
+type Build struct {
+	Fingerprint string `xml:"build_fingerprint,attr"`
+	Device      string `xml:"build_device,attr"`
+	Model       string `xml:"build_model,attr"`
+	ID          string `xml:"build_id,attr"`
+}
+
+// Module is the result of one module, e.g. csuite_com.example.app, on one
+// ABI.
+type Module struct {
+	Name string `xml:"name,attr"`
+	ABI  string `xml:"abi,attr"`
+	// Runtime is in milliseconds.
+	Runtime   int64      `xml:"runtime,attr"`
+	Done      bool       `xml:"done,attr"`
+	Reason    *Reason    `xml:"Reason"`
+	TestCases []TestCase `xml:"TestCase"`
+}
+
+// Reason explains why a module did not complete.
+type Reason struct {
+	Message string `xml:"message,attr"`
+}
+
+// TestCase groups the tests of one test class.
+type TestCase struct {
+	Name  string `xml:"name,attr"`
+	Tests []Test `xml:"Test"`
+}
+
+// Test is one test method. AppLaunchTest names its tests after the package
+// they launch.
+type Test struct {
+	Name    string   `xml:"name,attr"`
+	Result  string   `xml:"result,attr"`
+	Failure *Failure `xml:"Failure"`
+}
+
+// Failure holds the failure message of a failed test.
+type Failure struct {
+	Message    string `xml:"message,attr"`
+	StackTrace string `xml:"StackTrace"`
+}
+
+// Parse parses the contents of a test_result.xml.
+func Parse(data []byte) (*Result, error) {
+	var r Result
+	if err := xml.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ParseFile parses a test_result.xml, or the one in the results directory
+// at path.
+func ParseFile(path string) (*Result, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, FileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Row is the result of testing one app on one ABI.
+type Row struct {
+	Package string `json:"package"`
+	Module  string `json:"module"`
+	ABI     string `json:"abi"`
+	Outcome string `json:"outcome"`
+	// Status is the harness status of a failed test, if its message has one.
+	Status string `json:"status,omitempty"`
+	// ErrorTypes lists the types of the app errors detected by the launch
+	// instrumentation, e.g. crash, ANR or a dropbox tag.
+	ErrorTypes []string `json:"error_types,omitempty"`
+	Message    string   `json:"message,omitempty"`
+}
+
+var errorTypePattern = regexp.MustCompile(`(?m)^### Type: ([^,\n]+),`)
+
+// Rows returns one row per test, in document order. A module that did not
+// complete without reporting any test gets a single failed row.
+func (r *Result) Rows() []Row {
+	var rows []Row
+	for _, m := range r.Modules {
+		modulePackage, _ := packagelist.PackageName(m.Name)
+		tests := 0
+		for _, tc := range m.TestCases {
+			for _, t := range tc.Tests {
+				tests++
+				row := Row{Package: t.Name, Module: m.Name, ABI: m.ABI}
+				if !packagelist.ValidPackageName(row.Package) && modulePackage != "" {
+					row.Package = modulePackage
+				}
+				classify(&row, t)
+				rows = append(rows, row)
+			}
+		}
+		if tests == 0 && (!m.Done || m.Reason != nil) {
+			row := Row{Package: modulePackage, Module: m.Name, ABI: m.ABI, Outcome: Fail}
+			if m.Reason != nil {
+				row.Message = m.Reason.Message
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// classify sets the outcome of row from the result of t. App launch
+// failures, which AppLaunchTest reports with the failure status, are
+// crashes; every other failure, e.g. an install error, is a plain failure.
+func classify(row *Row, t Test) {
+	switch strings.ToLower(t.Result) {
+	case "pass":
+		row.Outcome = Pass
+		return
+	case "ignored", "assumption_failure":
+		row.Outcome = Skip
+		return
+	}
+	row.Outcome = Fail
+	if t.Failure == nil {
+		return
+	}
+	row.Message = t.Failure.Message
+	if row.Message == "" {
+		row.Message = t.Failure.StackTrace
+	}
+	if i := strings.Index(row.Message, ":"); i > 0 {
+		switch status := row.Message[:i]; status {
+		case StatusSuccess, StatusError, StatusFailure:
+			row.Status = status
+			row.Message = row.Message[i+1:]
+		}
+	}
+	if row.Status == StatusFailure {
+		row.Outcome = Crash
+	}
+	seen := make(map[string]bool)
+	for _, m := range errorTypePattern.FindAllStringSubmatch(row.Message, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			row.ErrorTypes = append(row.ErrorTypes, m[1])
+		}
+	}
+}
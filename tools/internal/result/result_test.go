@@ -0,0 +1,121 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testResult = `<?xml version='1.0' encoding='UTF-8' standalone='no' ?>
+<Result start="1600000000000" end="1600000600000" suite_name="CSUITE" suite_plan="launch">
+  <Build build_fingerprint="google/device/device:11/RP1A/1:userdebug/dev-keys" build_model="Pixel" />
+  <Summary pass="1" failed="2" modules_done="3" modules_total="4" />
+  <Module name="csuite_com.example.pass" abi="arm64-v8a" runtime="1200" done="true" pass="1">
+    <TestCase name="AppLaunchTest">
+      <Test result="pass" name="com.example.pass" />
+    </TestCase>
+  </Module>
+  <Module name="csuite_com.example.crash" abi="arm64-v8a" runtime="3400" done="true" pass="0">
+    <TestCase name="AppLaunchTest">
+      <Test result="fail" name="com.example.crash">
+        <Failure message="failure:Error(s) detected for package: com.example.crash&#10;&#10;### Type: crash, Details:&#10;java.lang.NullPointerException&#10;&#10;### Type: data_app_crash, Details:&#10;...">
+          <StackTrace>java.lang.NullPointerException</StackTrace>
+        </Failure>
+      </Test>
+    </TestCase>
+  </Module>
+  <Module name="csuite_com.example.error" abi="arm64-v8a" runtime="10" done="true" pass="0">
+    <TestCase name="AppLaunchTest">
+      <Test result="fail" name="com.example.error">
+        <Failure message="error:FAILED" />
+      </Test>
+    </TestCase>
+  </Module>
+  <Module name="csuite_com.example.incomplete" abi="arm64-v8a" runtime="0" done="false" pass="0">
+    <Reason message="Failed to install com.example.incomplete" />
+  </Module>
+</Result>`
+
+func TestParse_readsBuildAndModules(t *testing.T) {
+	r, err := Parse([]byte(testResult))
+
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if r.SuitePlan != "launch" || r.Start != 1600000000000 || r.Build.Model != "Pixel" {
+		t.Errorf("got %+v", r)
+	}
+	if len(r.Modules) != 4 || r.Modules[1].Runtime != 3400 || r.Modules[3].Done {
+		t.Errorf("got modules %+v", r.Modules)
+	}
+}
+
+func TestRows_classifiesEveryApp(t *testing.T) {
+	r, err := Parse([]byte(testResult))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	got := r.Rows()
+
+	want := []Row{
+		{Package: "com.example.pass", Module: "csuite_com.example.pass", ABI: "arm64-v8a", Outcome: Pass},
+		{Package: "com.example.crash", Module: "csuite_com.example.crash", ABI: "arm64-v8a", Outcome: Crash,
+			Status: StatusFailure, ErrorTypes: []string{"crash", "data_app_crash"},
+			Message: "Error(s) detected for package: com.example.crash\n\n### Type: crash, Details:\n" +
+				"java.lang.NullPointerException\n\n### Type: data_app_crash, Details:\n..."},
+		{Package: "com.example.error", Module: "csuite_com.example.error", ABI: "arm64-v8a", Outcome: Fail,
+			Status: StatusError, Message: "FAILED"},
+		{Package: "com.example.incomplete", Module: "csuite_com.example.incomplete", ABI: "arm64-v8a", Outcome: Fail,
+			Message: "Failed to install com.example.incomplete"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestRows_testNameIsNotAPackage_usesModulePackage(t *testing.T) {
+	r := &Result{Modules: []Module{{Name: "csuite_com.example.app", Done: true, TestCases: []TestCase{{
+		Name: "AppCompatibilityTest", Tests: []Test{{Name: "testAppStability", Result: "ASSUMPTION_FAILURE"}},
+	}}}}}
+
+	got := r.Rows()
+
+	if len(got) != 1 || got[0].Package != "com.example.app" || got[0].Outcome != Skip {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestParseFile_resultsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(testResult), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ParseFile(dir)
+
+	if err != nil || len(r.Modules) != 4 {
+		t.Errorf("ParseFile() = %v, %v", r, err)
+	}
+}
+
+func TestParse_notAResult_returnsError(t *testing.T) {
+	if _, err := Parse([]byte(`<configuration />`)); err == nil {
+		t.Error("Parse() succeeded, want error")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suite
+
+import (
+	"fmt"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	metadataOption       = "config-descriptor:metadata"
+	metadataFilterOption = "compatibility:module-metadata-include-filter"
+	planMetadataKey      = "plan"
+)
+
+// PlanConfigs returns the plan and every config it includes or uses as a
+// template-include default, parsed, each once, with the plan first.
+// Includes that do not resolve in s, e.g. configs from Tradefed's own jar,
+// are skipped.
+func (s *Suite) PlanConfigs(plan string) ([]*tfconfig.Configuration, error) {
+	if _, ok := s.Configs[plan]; !ok {
+		return nil, fmt.Errorf("plan %q not found", plan)
+	}
+	var configs []*tfconfig.Configuration
+	seen := make(map[string]bool)
+	var add func(name string) error
+	add = func(name string) error {
+		c, ok := s.Configs[name]
+		if !ok || seen[name] {
+			return nil
+		}
+		seen[name] = true
+		parsed, err := tfconfig.Parse(c.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.Path, err)
+		}
+		configs = append(configs, parsed)
+		for _, i := range parsed.Includes {
+			if err := add(i.Name); err != nil {
+				return err
+			}
+		}
+		for _, t := range parsed.TemplateIncludes {
+			if t.Default != "" {
+				if err := add(t.Default); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := add(plan); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// PlanMetadataFilters returns the plan metadata values that the plan and
+// the configs it includes filter modules on.
+func (s *Suite) PlanMetadataFilters(plan string) (map[string]bool, error) {
+	configs, err := s.PlanConfigs(plan)
+	if err != nil {
+		return nil, err
+	}
+	filters := make(map[string]bool)
+	for _, c := range configs {
+		for _, o := range c.AllOptions() {
+			if o.Name == metadataFilterOption && o.Key == planMetadataKey {
+				filters[o.Value] = true
+			}
+		}
+	}
+	return filters, nil
+}
+
+// PlanModules returns the module configs a plan selects, sorted by name:
+// those whose plan metadata matches one of its plan metadata filters, or
+// all of them when it sets no filter, as Tradefed does.
+func (s *Suite) PlanModules(plan string) ([]*Config, error) {
+	filters, err := s.PlanMetadataFilters(plan)
+	if err != nil {
+		return nil, err
+	}
+	var modules []*Config
+	for _, m := range s.SortedModules() {
+		parsed, err := tfconfig.Parse(m.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.Path, err)
+		}
+		selected := len(filters) == 0
+		for _, o := range parsed.AllOptions() {
+			if o.Name == metadataOption && o.Key == planMetadataKey && filters[o.Value] {
+				selected = true
+			}
+		}
+		if selected {
+			modules = append(modules, m)
+		}
+	}
+	return modules, nil
+}
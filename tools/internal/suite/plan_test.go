@@ -0,0 +1,96 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func planSuite() *Suite {
+	s := New()
+	for name, data := range map[string]string{
+		"launch": `<configuration>
+  <include name="csuite-base" />
+  <include name="everything" />
+  <template-include name="filters" default="launch-filters" />
+</configuration>`,
+		"csuite-base":    `<configuration><option name="enable-root" value="true" /></configuration>`,
+		"launch-filters": `<configuration><option name="compatibility:module-metadata-include-filter" key="plan" value="app-launch" /></configuration>`,
+		"all":            `<configuration />`,
+	} {
+		s.Configs[name] = &Config{Name: name, Path: name + ".xml", Data: []byte(data), Plan: true}
+	}
+	for name, plan := range map[string]string{
+		"csuite_com.example.b": "app-launch",
+		"csuite_com.example.a": "app-launch",
+		"csuite_com.example.c": "app-crawl",
+	} {
+		s.Modules[name] = &Config{Name: name, Data: []byte(`<configuration>
+  <target_preparer class="Preparer">
+    <option name="config-descriptor:metadata" key="plan" value="` + plan + `" />
+  </target_preparer>
+</configuration>`)}
+	}
+	return s
+}
+
+func moduleNames(modules []*Config) []string {
+	var names []string
+	for _, m := range modules {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func TestPlanConfigs_resolvesIncludesAndTemplateDefaults(t *testing.T) {
+	configs, err := planSuite().PlanConfigs("launch")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 3 {
+		t.Errorf("got %d configs, want launch, csuite-base and launch-filters", len(configs))
+	}
+}
+
+func TestPlanConfigs_unknownPlan_returnsError(t *testing.T) {
+	if _, err := planSuite().PlanConfigs("missing"); err == nil {
+		t.Error("got no error for a missing plan")
+	}
+}
+
+func TestPlanModules_selectsByPlanMetadata(t *testing.T) {
+	modules, err := planSuite().PlanModules("launch")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := moduleNames(modules), []string{"csuite_com.example.a", "csuite_com.example.b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlanModules_noFilter_selectsAllModulesSortedByName(t *testing.T) {
+	modules, err := planSuite().PlanModules("all")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"csuite_com.example.a", "csuite_com.example.b", "csuite_com.example.c"}
+	if got := moduleNames(modules); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want all modules sorted by name %v", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package suite finds the Tradefed configs that make up a built C-Suite,
+// either in the android-csuite zip or in a directory such as an extracted
+// suite or a checked-in config directory.
+//
+// Configs packaged under config/ in the suite jars, or loose .xml files, are
+// resolvable by name, e.g. "launch" for config/launch.xml. Module configs are
+// the .config files under testcases/, named after the module.
+package suite
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	configDir             = "config"
+	testcasesDir          = "testcases"
+	planJarPrefix         = "csuite"
+	jarExtension          = ".jar"
+	xmlExtension          = ".xml"
+	moduleConfigExtension = ".config"
+)
+
+// Config is a Tradefed config found in a suite.
+type Config struct {
+	// Name is the name Tradefed resolves the config by.
+	Name string
+	// Path locates the config for humans. Entries inside a jar are shown as
+	// "<jar path>!/<entry path>".
+	Path string
+	Data []byte
+	// Plan is true for configs that belong to C-Suite itself, as opposed to
+	// configs from other jars that are only there to resolve includes.
+	Plan bool
+}
+
+// Suite is the set of configs found in a suite.
+type Suite struct {
+	// Configs holds every config resolvable by name, keyed by name.
+	Configs map[string]*Config
+	// Modules holds the module configs under testcases, keyed by module name.
+	Modules map[string]*Config
+	// TestFiles holds the paths of all files under testcases, relative to
+	// that directory.
+	TestFiles map[string]bool
+}
+
+// New returns an empty Suite.
+func New() *Suite {
+	return &Suite{
+		Configs:   make(map[string]*Config),
+		Modules:   make(map[string]*Config),
+		TestFiles: make(map[string]bool),
+	}
+}
+
+// Open reads the suite at p, which is a directory, a suite zip or a single
+// jar. All configs in a jar opened directly are treated as plans.
+func Open(p string) (*Suite, error) {
+	fsys, closer, err := OpenFS(p)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	s := New()
+	if r, ok := fsys.(*zip.ReadCloser); ok && strings.HasSuffix(p, jarExtension) {
+		err = s.addJar(&r.Reader, p, true)
+	} else {
+		err = s.addFS(fsys, p)
+	}
+	return s, err
+}
+
+// OpenFS returns the files of the directory or zip at p, for callers that
+// need more than the configs. The returned closer must be closed once the
+// files are no longer needed.
+func OpenFS(p string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(p), nopCloser{}, nil
+	}
+	r, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", p, err)
+	}
+	return r, r, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Merge adds the configs of other that do not clash with configs already in
+// s. They are added as non-plan configs, so that they are only used to
+// resolve includes.
+func (s *Suite) Merge(other *Suite) {
+	for name, c := range other.Configs {
+		if _, ok := s.Configs[name]; !ok {
+			reference := *c
+			reference.Plan = false
+			s.Configs[name] = &reference
+		}
+	}
+}
+
+// Plans returns the plan configs sorted by name.
+func (s *Suite) Plans() []*Config {
+	var plans []*Config
+	for _, c := range s.Configs {
+		if c.Plan {
+			plans = append(plans, c)
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Name < plans[j].Name })
+	return plans
+}
+
+// HasTestFile reports whether a file named name is under testcases. Tradefed
+// finds test-file-name values by name anywhere below testcases, e.g. in
+// testcases/<module>/, so a name matches any file with the same base name,
+// as well as the path relative to testcases.
+func (s *Suite) HasTestFile(name string) bool {
+	if s.TestFiles[name] {
+		return true
+	}
+	base := path.Base(name)
+	for p := range s.TestFiles {
+		if path.Base(p) == base {
+			return true
+		}
+	}
+	return false
+}
+
+// SortedModules returns the module configs sorted by module name.
+func (s *Suite) SortedModules() []*Config {
+	var modules []*Config
+	for _, c := range s.Modules {
+		modules = append(modules, c)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules
+}
+
+func (s *Suite) addFS(fsys fs.FS, root string) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		display := path.Join(root, p)
+		if rel, ok := relativeTo(p, testcasesDir); ok {
+			s.TestFiles[rel] = true
+			if strings.HasSuffix(p, moduleConfigExtension) {
+				data, err := fs.ReadFile(fsys, p)
+				if err != nil {
+					return err
+				}
+				name := strings.TrimSuffix(path.Base(p), moduleConfigExtension)
+				s.addConfig(s.Modules, &Config{Name: name, Path: display, Data: data, Plan: true})
+			}
+			return nil
+		}
+		switch path.Ext(p) {
+		case jarExtension:
+			data, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				return fmt.Errorf("%s: %v", display, err)
+			}
+			return s.addJar(r, display, strings.HasPrefix(path.Base(p), planJarPrefix))
+		case xmlExtension:
+			data, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			name := p
+			if rel, ok := relativeTo(p, configDir); ok {
+				name = rel
+			}
+			name = strings.TrimSuffix(name, xmlExtension)
+			s.addConfig(s.Configs, &Config{Name: name, Path: display, Data: data, Plan: true})
+		}
+		return nil
+	})
+}
+
+func (s *Suite) addJar(r *zip.Reader, display string, plan bool) error {
+	for _, f := range r.File {
+		if !strings.HasPrefix(f.Name, configDir+"/") || !strings.HasSuffix(f.Name, xmlExtension) {
+			continue
+		}
+		data, err := fs.ReadFile(r, f.Name)
+		if err != nil {
+			return fmt.Errorf("%s!/%s: %v", display, f.Name, err)
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(f.Name, configDir+"/"), xmlExtension)
+		s.addConfig(s.Configs, &Config{
+			Name: name,
+			Path: display + "!/" + f.Name,
+			Data: data,
+			Plan: plan,
+		})
+	}
+	return nil
+}
+
+// addConfig keeps the first config found for a name, the same way the first
+// jar on the classpath wins in Tradefed.
+func (s *Suite) addConfig(configs map[string]*Config, c *Config) {
+	if _, ok := configs[c.Name]; !ok {
+		configs[c.Name] = c
+	}
+}
+
+// relativeTo returns p relative to the last path element named dir.
+func relativeTo(p, dir string) (string, bool) {
+	elems := strings.Split(p, "/")
+	for i := len(elems) - 2; i >= 0; i-- {
+		if elems[i] == dir {
+			return strings.Join(elems[i+1:], "/"), true
+		}
+	}
+	return "", false
+}
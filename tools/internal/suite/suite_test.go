@@ -0,0 +1,171 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suite
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpen_suiteZip_findsConfigsModulesAndTestFiles(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "android-csuite.zip")
+	writeZip(t, zipPath, map[string][]byte{
+		"android-csuite/tools/csuite-tradefed.jar": zipBytes(t, map[string][]byte{
+			"config/launch.xml":      []byte("<configuration />"),
+			"config/csuite-base.xml": []byte("<configuration />"),
+			"com/android/Foo.class":  nil,
+		}),
+		"android-csuite/tools/tradefed.jar": zipBytes(t, map[string][]byte{
+			"config/everything.xml": []byte("<configuration />"),
+		}),
+		"android-csuite/testcases/csuite_com.example.config":         []byte("<configuration />"),
+		"android-csuite/testcases/csuite-launch-instrumentation.apk": nil,
+	})
+
+	s, err := Open(zipPath)
+
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if got, want := planNames(s), []string{"csuite-base", "launch"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got plans %v, want %v", got, want)
+	}
+	if c, ok := s.Configs["everything"]; !ok || c.Plan {
+		t.Errorf("got everything config %v, want non-plan config", c)
+	}
+	if want := zipPath + "/android-csuite/tools/csuite-tradefed.jar!/config/launch.xml"; s.Configs["launch"].Path != want {
+		t.Errorf("got path %q, want %q", s.Configs["launch"].Path, want)
+	}
+	if _, ok := s.Modules["csuite_com.example"]; !ok || len(s.Modules) != 1 {
+		t.Errorf("got modules %v", s.Modules)
+	}
+	if want := map[string]bool{"csuite_com.example.config": true, "csuite-launch-instrumentation.apk": true}; !reflect.DeepEqual(s.TestFiles, want) {
+		t.Errorf("got test files %v, want %v", s.TestFiles, want)
+	}
+}
+
+func TestOpen_configDirectory_namesConfigsRelativeToConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config", "launch.xml"), "<configuration />")
+	writeFile(t, filepath.Join(dir, "config", "suite", "base.xml"), "<configuration />")
+	writeFile(t, filepath.Join(dir, "README"), "")
+
+	s, err := Open(dir)
+
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if got, want := planNames(s), []string{"launch", "suite/base"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got plans %v, want %v", got, want)
+	}
+}
+
+func TestOpen_jar_treatsAllConfigsAsPlans(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "tradefed.jar")
+	writeZip(t, jarPath, map[string][]byte{"config/everything.xml": []byte("<configuration />")})
+
+	s, err := Open(jarPath)
+
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if got, want := planNames(s), []string{"everything"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got plans %v, want %v", got, want)
+	}
+}
+
+func TestMerge_addsMissingConfigsAsNonPlans(t *testing.T) {
+	s := New()
+	s.Configs["launch"] = &Config{Name: "launch", Plan: true}
+	other := New()
+	other.Configs["launch"] = &Config{Name: "launch", Path: "other", Plan: true}
+	other.Configs["everything"] = &Config{Name: "everything", Plan: true}
+
+	s.Merge(other)
+
+	if s.Configs["launch"].Path == "other" {
+		t.Error("Merge() replaced an existing config")
+	}
+	if c := s.Configs["everything"]; c == nil || c.Plan {
+		t.Errorf("got %v, want non-plan config", c)
+	}
+	if other.Configs["everything"].Plan != true {
+		t.Error("Merge() modified the merged suite")
+	}
+}
+
+func planNames(s *Suite) []string {
+	var names []string
+	for _, c := range s.Plans() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, p string, files map[string][]byte) {
+	t.Helper()
+	if err := os.WriteFile(p, zipBytes(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasTestFile_matchesFilesInModuleDirectories(t *testing.T) {
+	s := New()
+	s.TestFiles["csuite_com.example/csuite-launch-instrumentation.apk"] = true
+
+	for name, want := range map[string]bool{
+		"csuite-launch-instrumentation.apk":                    true,
+		"csuite_com.example/csuite-launch-instrumentation.apk": true,
+		"other.apk": false,
+	} {
+		if got := s.HasTestFile(name); got != want {
+			t.Errorf("HasTestFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
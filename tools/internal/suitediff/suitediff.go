@@ -0,0 +1,351 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package suitediff compares the plans and module configs of two suites.
+package suitediff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+// Statuses of a config in a Diff.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// ConfigScope is the scope of options set on the configuration itself.
+const ConfigScope = "configuration"
+
+// Diff lists the plans and module configs that differ between two suites.
+type Diff struct {
+	Plans   []ConfigDiff `json:"plans"`
+	Modules []ConfigDiff `json:"modules"`
+}
+
+// ConfigDiff describes how one config differs.
+type ConfigDiff struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Changes is only set for changed configs.
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Change is one difference inside a changed config.
+type Change struct {
+	// Scope is ConfigScope, or the type and class of the object the change
+	// is in, e.g. "test com.android.compatibility.testtype.AppLaunchTest".
+	Scope string `json:"scope"`
+	// Kind is one of description, include, template-include, object,
+	// option or content. Content changes are reported when either version
+	// does not parse.
+	Kind string `json:"kind"`
+	// Name identifies what changed within its kind, e.g. the option name
+	// followed by its key in brackets for map options.
+	Name   string   `json:"name,omitempty"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// Compare returns the differences from old to new.
+func Compare(old, new *suite.Suite) *Diff {
+	return &Diff{
+		Plans:   compareConfigs(configsByName(old.Plans()), configsByName(new.Plans())),
+		Modules: compareConfigs(old.Modules, new.Modules),
+	}
+}
+
+// Empty reports whether the suites compared equal.
+func (d *Diff) Empty() bool {
+	return len(d.Plans) == 0 && len(d.Modules) == 0
+}
+
+func configsByName(configs []*suite.Config) map[string]*suite.Config {
+	m := make(map[string]*suite.Config)
+	for _, c := range configs {
+		m[c.Name] = c
+	}
+	return m
+}
+
+func compareConfigs(old, new map[string]*suite.Config) []ConfigDiff {
+	var diffs []ConfigDiff
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			diffs = append(diffs, ConfigDiff{Name: name, Status: Removed})
+			continue
+		}
+		if bytes.Equal(o.Data, n.Data) {
+			continue
+		}
+		if changes := compareContent(o.Data, n.Data); len(changes) > 0 {
+			diffs = append(diffs, ConfigDiff{Name: name, Status: Changed, Changes: changes})
+		}
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			diffs = append(diffs, ConfigDiff{Name: name, Status: Added})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// compareContent returns the semantic changes between two versions of a
+// config. Formatting and comment changes are not reported.
+func compareContent(old, new []byte) []Change {
+	o, oldErr := tfconfig.Parse(old)
+	n, newErr := tfconfig.Parse(new)
+	if oldErr != nil || newErr != nil {
+		return []Change{{Scope: ConfigScope, Kind: "content"}}
+	}
+
+	var changes []Change
+	if o.Description != n.Description {
+		changes = append(changes, Change{
+			Scope: ConfigScope, Kind: "description",
+			Before: nonEmpty(o.Description), After: nonEmpty(n.Description),
+		})
+	}
+	changes = append(changes, compareSets(ConfigScope, "include", includes(o), includes(n))...)
+	changes = append(changes, compareValues(ConfigScope, "template-include", templateIncludes(o), templateIncludes(n))...)
+	changes = append(changes, compareSets(ConfigScope, "object", objects(o), objects(n))...)
+	changes = append(changes, compareValues(ConfigScope, "option", options(o.Options), options(n.Options))...)
+
+	oldObjects, newObjects := objectOptions(o), objectOptions(n)
+	for _, scope := range unionKeys(oldObjects, newObjects) {
+		changes = append(changes, compareValues(scope, "option", oldObjects[scope], newObjects[scope])...)
+	}
+	return changes
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+func includes(c *tfconfig.Configuration) []string {
+	var names []string
+	for _, i := range c.Includes {
+		names = append(names, i.Name)
+	}
+	return names
+}
+
+func templateIncludes(c *tfconfig.Configuration) map[string][]string {
+	m := make(map[string][]string)
+	for _, i := range c.TemplateIncludes {
+		m[i.Name] = append(m[i.Name], i.Default)
+	}
+	return m
+}
+
+func objectScope(o *tfconfig.Object) string {
+	return strings.TrimSpace(o.Type() + " " + o.Class)
+}
+
+func objects(c *tfconfig.Configuration) []string {
+	var scopes []string
+	for i := range c.Objects {
+		scopes = append(scopes, objectScope(&c.Objects[i]))
+	}
+	return scopes
+}
+
+// OptionName returns the name an option is reported by: its name, followed
+// by its key in brackets for map options.
+func OptionName(o tfconfig.Option) string {
+	if o.Key == "" {
+		return o.Name
+	}
+	return o.Name + "[" + o.Key + "]"
+}
+
+func options(opts []tfconfig.Option) map[string][]string {
+	m := make(map[string][]string)
+	for _, o := range opts {
+		name := OptionName(o)
+		m[name] = append(m[name], o.Value)
+	}
+	return m
+}
+
+// objectOptions groups the options of all objects by object scope. Objects
+// with the same type and class share a scope.
+func objectOptions(c *tfconfig.Configuration) map[string]map[string][]string {
+	m := make(map[string]map[string][]string)
+	for i := range c.Objects {
+		scope := objectScope(&c.Objects[i])
+		if m[scope] == nil {
+			m[scope] = make(map[string][]string)
+		}
+		for name, values := range options(c.Objects[i].Options) {
+			m[scope][name] = append(m[scope][name], values...)
+		}
+	}
+	return m
+}
+
+// compareSets reports the elements added to and removed from a list, as one
+// change per element.
+func compareSets(scope, kind string, old, new []string) []Change {
+	var changes []Change
+	removed, added := Difference(old, new), Difference(new, old)
+	for _, name := range removed {
+		changes = append(changes, Change{Scope: scope, Kind: kind, Name: name, Before: []string{name}})
+	}
+	for _, name := range added {
+		changes = append(changes, Change{Scope: scope, Kind: kind, Name: name, After: []string{name}})
+	}
+	return changes
+}
+
+// compareValues reports every name whose values differ between old and new.
+func compareValues(scope, kind string, old, new map[string][]string) []Change {
+	var changes []Change
+	for _, name := range unionKeys(old, new) {
+		before, after := sorted(old[name]), sorted(new[name])
+		if equal(before, after) {
+			continue
+		}
+		changes = append(changes, Change{Scope: scope, Kind: kind, Name: name, Before: before, After: after})
+	}
+	return changes
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sorted(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	s := append([]string(nil), values...)
+	sort.Strings(s)
+	return s
+}
+
+// Difference returns the elements of a missing from b, counting duplicates,
+// sorted.
+func Difference(a, b []string) []string {
+	counts := make(map[string]int)
+	for _, s := range b {
+		counts[s]++
+	}
+	var diff []string
+	for _, s := range a {
+		if counts[s] > 0 {
+			counts[s]--
+			continue
+		}
+		diff = append(diff, s)
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String describes the change on one line.
+func (c Change) String() string {
+	what := c.Kind
+	if c.Name != "" {
+		what += " " + c.Name
+	}
+	if c.Scope != ConfigScope {
+		what = c.Scope + ": " + what
+	}
+	switch {
+	case c.Kind == "content":
+		return what + " changed (unparseable)"
+	case len(c.Before) == 0:
+		return what + " added" + c.values(c.After)
+	case len(c.After) == 0:
+		return what + " removed" + c.values(c.Before)
+	}
+	return fmt.Sprintf("%s: %s -> %s", what, strings.Join(c.Before, ", "), strings.Join(c.After, ", "))
+}
+
+// values formats the values of an added or removed element, omitting them
+// when they say nothing more than its name.
+func (c Change) values(v []string) string {
+	if len(v) == 1 && (v[0] == "" || v[0] == c.Name) {
+		return ""
+	}
+	return ": " + strings.Join(v, ", ")
+}
+
+// WriteText writes d in a human-readable form.
+func (d *Diff) WriteText(w io.Writer) error {
+	var b bytes.Buffer
+	for _, section := range []struct {
+		title string
+		diffs []ConfigDiff
+	}{{"Plans", d.Plans}, {"Modules", d.Modules}} {
+		if len(section.diffs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n", section.title)
+		for _, cd := range section.diffs {
+			fmt.Fprintf(&b, "  %s %s\n", statusMarker(cd.Status), cd.Name)
+			for _, c := range cd.Changes {
+				fmt.Fprintf(&b, "      %s\n", c)
+			}
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func statusMarker(status string) string {
+	switch status {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	}
+	return "~"
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suitediff
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+const oldLaunch = `<configuration description="C-Suite Launch">
+  <include name="csuite-base" />
+  <option name="compatibility:module-metadata-include-filter" key="plan" value="app-launch" />
+  <test class="com.android.compatibility.testtype.AppLaunchTest">
+    <option name="retry-count" value="5" />
+  </test>
+</configuration>`
+
+const newLaunch = `<configuration description="C-Suite Launch">
+  <!-- Reformatted, with a comment. -->
+  <include name="csuite-base" />
+  <include name="basic-reporters" />
+  <option name="compatibility:module-metadata-include-filter"
+          key="plan" value="csuite-launch" />
+  <test class="com.android.compatibility.testtype.AppLaunchTest" />
+</configuration>`
+
+func TestCompare_reportsAddedRemovedAndChangedConfigs(t *testing.T) {
+	old := newSuite(map[string]string{"launch": oldLaunch, "removed": "<configuration />"},
+		map[string]string{"csuite_com.example.a": "<configuration />", "csuite_com.example.b": "<configuration />"})
+	new := newSuite(map[string]string{"launch": newLaunch, "added": "<configuration />"},
+		map[string]string{"csuite_com.example.a": "<configuration />\n", "csuite_com.example.c": "<configuration />"})
+
+	d := Compare(old, new)
+
+	if got, want := statuses(d.Plans), []string{"added added", "launch changed", "removed removed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got plans %v, want %v", got, want)
+	}
+	if got, want := statuses(d.Modules), []string{"csuite_com.example.b removed", "csuite_com.example.c added"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got modules %v, want %v", got, want)
+	}
+}
+
+func TestCompare_changedConfig_reportsSemanticChanges(t *testing.T) {
+	old := newSuite(map[string]string{"launch": oldLaunch}, nil)
+	new := newSuite(map[string]string{"launch": newLaunch}, nil)
+
+	d := Compare(old, new)
+
+	if len(d.Plans) != 1 {
+		t.Fatalf("got plans %+v, want one changed plan", d.Plans)
+	}
+	want := []Change{
+		{Scope: ConfigScope, Kind: "include", Name: "basic-reporters", After: []string{"basic-reporters"}},
+		{Scope: ConfigScope, Kind: "option", Name: "compatibility:module-metadata-include-filter[plan]",
+			Before: []string{"app-launch"}, After: []string{"csuite-launch"}},
+		{Scope: "test com.android.compatibility.testtype.AppLaunchTest", Kind: "option", Name: "retry-count",
+			Before: []string{"5"}},
+	}
+	if got := d.Plans[0].Changes; !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %+v, want %+v", got, want)
+	}
+}
+
+func TestCompare_unparseableConfig_reportsContentChange(t *testing.T) {
+	old := newSuite(map[string]string{"launch": oldLaunch}, nil)
+	new := newSuite(map[string]string{"launch": "<configuration>"}, nil)
+
+	d := Compare(old, new)
+
+	if len(d.Plans) != 1 || len(d.Plans[0].Changes) != 1 || d.Plans[0].Changes[0].Kind != "content" {
+		t.Errorf("got %+v, want one content change", d.Plans)
+	}
+}
+
+func TestCompare_identicalSuites_isEmpty(t *testing.T) {
+	s := newSuite(map[string]string{"launch": oldLaunch}, map[string]string{"csuite_com.example.a": "<configuration />"})
+
+	if d := Compare(s, s); !d.Empty() {
+		t.Errorf("got %+v, want empty diff", d)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	d := &Diff{
+		Plans: []ConfigDiff{{Name: "launch", Status: Changed, Changes: []Change{
+			{Scope: ConfigScope, Kind: "include", Name: "basic-reporters", After: []string{"basic-reporters"}},
+			{Scope: "test com.example.Test", Kind: "option", Name: "retry-count", Before: []string{"5"}},
+			{Scope: ConfigScope, Kind: "option", Name: "plan", Before: []string{"a"}, After: []string{"b"}},
+		}}},
+		Modules: []ConfigDiff{{Name: "csuite_com.example.a", Status: Added}},
+	}
+	var b bytes.Buffer
+
+	if err := d.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Plans:
+  ~ launch
+      include basic-reporters added
+      test com.example.Test: option retry-count removed: 5
+      option plan: a -> b
+Modules:
+  + csuite_com.example.a
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestDiff_json(t *testing.T) {
+	d := &Diff{Modules: []ConfigDiff{{Name: "csuite_com.example.a", Status: Removed}}}
+
+	got, err := json.Marshal(d)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"plans":null,"modules":[{"name":"csuite_com.example.a","status":"removed"}]}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func newSuite(plans, modules map[string]string) *suite.Suite {
+	s := suite.New()
+	for name, data := range plans {
+		s.Configs[name] = &suite.Config{Name: name, Data: []byte(data), Plan: true}
+	}
+	for name, data := range modules {
+		s.Modules[name] = &suite.Config{Name: name, Data: []byte(data)}
+	}
+	return s
+}
+
+func statuses(diffs []ConfigDiff) []string {
+	var s []string
+	for _, d := range diffs {
+		s = append(s, d.Name+" "+d.Status)
+	}
+	return s
+}
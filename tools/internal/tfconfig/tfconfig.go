@@ -0,0 +1,259 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tfconfig reads Trade Federation configuration files, such as the
+// C-Suite plans and the per-app module configs.
+package tfconfig
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RootElement is the name of the root element of every Tradefed config.
+const RootElement = "configuration"
+
+// Configuration is a parsed Tradefed config.
+type Configuration struct {
+	XMLName          xml.Name          `xml:"configuration"`
+	Description      string            `xml:"description,attr,omitempty"`
+	Includes         []Include         `xml:"include"`
+	TemplateIncludes []TemplateInclude `xml:"template-include"`
+	Options          []Option          `xml:"option"`
+	// Objects holds every other element, e.g. target_preparer, test or
+	// result_reporter, in document order.
+	Objects []Object `xml:",any"`
+}
+
+// Include pulls another config in by name.
+type Include struct {
+	Name string `xml:"name,attr"`
+}
+
+// TemplateInclude is a placeholder for a config that is chosen on the
+// command line, falling back to Default when none is given.
+type TemplateInclude struct {
+	Name    string `xml:"name,attr"`
+	Default string `xml:"default,attr,omitempty"`
+}
+
+// Option sets a named option, either on the configuration itself or on the
+// object it is nested in. Key is only set for map options.
+type Option struct {
+	Name  string `xml:"name,attr"`
+	Key   string `xml:"key,attr,omitempty"`
+	Value string `xml:"value,attr"`
+}
+
+// Object is a configuration object such as a target preparer or a test.
+type Object struct {
+	XMLName xml.Name
+	Class   string   `xml:"class,attr,omitempty"`
+	Options []Option `xml:"option"`
+}
+
+// Type returns the object type, e.g. "target_preparer".
+func (o *Object) Type() string {
+	return o.XMLName.Local
+}
+
+// OptionValues returns the values of all options with the given name.
+func OptionValues(options []Option, name string) []string {
+	var values []string
+	for _, o := range options {
+		if o.Name == name {
+			values = append(values, o.Value)
+		}
+	}
+	return values
+}
+
+// AllOptions returns the configuration options followed by the options of
+// every object, in document order.
+func (c *Configuration) AllOptions() []Option {
+	options := append([]Option(nil), c.Options...)
+	for _, o := range c.Objects {
+		options = append(options, o.Options...)
+	}
+	return options
+}
+
+// ObjectsOfClass returns the objects whose class is className.
+func (c *Configuration) ObjectsOfClass(className string) []*Object {
+	var objects []*Object
+	for i := range c.Objects {
+		if c.Objects[i].Class == className {
+			objects = append(objects, &c.Objects[i])
+		}
+	}
+	return objects
+}
+
+// CheckWellFormed reports whether data is a single well-formed XML document.
+func CheckWellFormed(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	roots := 0
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if roots != 1 {
+		return fmt.Errorf("expected exactly one root element, found %d", roots)
+	}
+	return nil
+}
+
+// Parse parses a Tradefed config. It fails if data is not well-formed XML or
+// if its root element is not <configuration>.
+func Parse(data []byte) (*Configuration, error) {
+	if err := CheckWellFormed(data); err != nil {
+		return nil, err
+	}
+	var c Configuration
+	if err := xml.Unmarshal(data, &c); err != nil {
+		if _, ok := err.(xml.UnmarshalError); ok {
+			return nil, fmt.Errorf("root element must be <%s>: %v", RootElement, err)
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseFile parses the Tradefed config at path.
+func ParseFile(path string) (*Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// generatedHeader matches the header written by generate_module.py.
+const generatedHeader = `<?xml version="1.0" encoding="utf-8"?>
+<!-- Copyright (C) 2020 The Android Open Source Project
+     Licensed under the Apache License, Version 2.0 (the "License");
+     you may not use this file except in compliance with the License.
+     You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+     Unless required by applicable law or agreed to in writing, software
+     distributed under the License is distributed on an "AS IS" BASIS,
+     WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+     See the License for the specific language governing permissions and
+     limitations under the License.
+-->
+<!-- This file was auto-generated by %s.
+     Do not edit manually.
+-->
+`
+
+const indent = "    "
+
+// Marshal returns c as XML, with includes, template includes, options and
+// objects written in that order.
+func Marshal(c *Configuration) []byte {
+	var b bytes.Buffer
+	b.WriteString("<" + RootElement)
+	writeAttrs(&b, "description", c.Description)
+	b.WriteString(">\n")
+	for _, i := range c.Includes {
+		b.WriteString(indent + "<include")
+		writeAttrs(&b, "name", i.Name)
+		b.WriteString(" />\n")
+	}
+	for _, i := range c.TemplateIncludes {
+		b.WriteString(indent + "<template-include")
+		writeAttrs(&b, "name", i.Name, "default", i.Default)
+		b.WriteString(" />\n")
+	}
+	writeOptions(&b, indent, c.Options)
+	for _, o := range c.Objects {
+		b.WriteString(indent + "<" + o.Type())
+		writeAttrs(&b, "class", o.Class)
+		if len(o.Options) == 0 {
+			b.WriteString(" />\n")
+			continue
+		}
+		b.WriteString(">\n")
+		writeOptions(&b, indent+indent, o.Options)
+		b.WriteString(indent + "</" + o.Type() + ">\n")
+	}
+	b.WriteString("</" + RootElement + ">\n")
+	return b.Bytes()
+}
+
+// MarshalGenerated returns c as XML preceded by the license header and a
+// note that the file was generated by generator, which is the path of the
+// generating tool in the source tree.
+func MarshalGenerated(c *Configuration, generator string) []byte {
+	return append([]byte(fmt.Sprintf(generatedHeader, generator)), Marshal(c)...)
+}
+
+func writeOptions(b *bytes.Buffer, prefix string, options []Option) {
+	for _, o := range options {
+		b.WriteString(prefix + "<option")
+		writeAttrs(b, "name", o.Name, "key", o.Key, "value", o.Value)
+		b.WriteString(" />\n")
+	}
+}
+
+// writeAttrs writes the given name and value pairs as attributes, skipping
+// empty values except for the value attribute of options.
+func writeAttrs(b *bytes.Buffer, pairs ...string) {
+	for i := 0; i < len(pairs); i += 2 {
+		name, value := pairs[i], pairs[i+1]
+		if value == "" && name != "value" {
+			continue
+		}
+		b.WriteString(" " + name + "=\"" + EscapeAttr(value) + "\"")
+	}
+}
+
+var attrEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"\"", "&quot;",
+	"\n", "&#xA;",
+	"\t", "&#x9;",
+)
+
+// EscapeAttr escapes s for use in a double-quoted attribute value.
+func EscapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tfconfig
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const launchPlan = `<?xml version="1.0" encoding="utf-8"?>
+<configuration description="C-Suite Compatibility Launch Test Plan">
+  <include name="csuite-base" />
+  <template-include name="reporters" default="basic-reporters" />
+  <option name="plan" value="launch" />
+  <target_preparer class="com.android.compatibility.targetprep.AppSetupPreparer">
+    <option name="package-name" value="com.example.app" />
+  </target_preparer>
+  <test class="com.android.compatibility.testtype.AppLaunchTest" />
+</configuration>
+`
+
+func TestParse_validConfig_returnsAllElements(t *testing.T) {
+	c, err := Parse([]byte(launchPlan))
+
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if c.Description != "C-Suite Compatibility Launch Test Plan" {
+		t.Errorf("got description %q", c.Description)
+	}
+	if want := []Include{{Name: "csuite-base"}}; !reflect.DeepEqual(c.Includes, want) {
+		t.Errorf("got includes %v, want %v", c.Includes, want)
+	}
+	if want := []TemplateInclude{{Name: "reporters", Default: "basic-reporters"}}; !reflect.DeepEqual(c.TemplateIncludes, want) {
+		t.Errorf("got template includes %v, want %v", c.TemplateIncludes, want)
+	}
+	if len(c.Objects) != 2 || c.Objects[0].Type() != "target_preparer" || c.Objects[1].Type() != "test" {
+		t.Errorf("got objects %v", c.Objects)
+	}
+}
+
+func TestAllOptions_includesObjectOptions(t *testing.T) {
+	c, err := Parse([]byte(launchPlan))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	got := OptionValues(c.AllOptions(), "package-name")
+
+	if want := []string{"com.example.app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestObjectsOfClass_returnsMatchingObjects(t *testing.T) {
+	c, err := Parse([]byte(launchPlan))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	got := c.ObjectsOfClass("com.android.compatibility.testtype.AppLaunchTest")
+
+	if len(got) != 1 || got[0].Type() != "test" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestParse_rootIsNotConfiguration_returnsError(t *testing.T) {
+	_, err := Parse([]byte(`<plan><option name="a" value="b" /></plan>`))
+
+	if err == nil {
+		t.Error("Parse() succeeded, want error")
+	}
+}
+
+func TestParse_malformedXml_returnsError(t *testing.T) {
+	for _, data := range []string{
+		`<configuration><option name="a" value="b"></configuration>`,
+		`<configuration><option name="a" value="x & y" /></configuration>`,
+		`<configuration />trailing<configuration />`,
+		``,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", data)
+		}
+	}
+}
+
+func TestMarshal_roundTripsThroughParse(t *testing.T) {
+	c, err := Parse([]byte(launchPlan))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	got, err := Parse(Marshal(c))
+
+	if err != nil {
+		t.Fatalf("Parse(Marshal()) failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestMarshal_writesIndentedSelfClosingElements(t *testing.T) {
+	c := &Configuration{
+		Description: "Top apps & games",
+		Options:     []Option{{Name: "compatibility:include-filter", Value: "csuite_com.example.app"}},
+		Objects: []Object{{
+			XMLName: xml.Name{Local: "test"},
+			Class:   "com.android.compatibility.testtype.AppLaunchTest",
+		}},
+	}
+
+	got := string(Marshal(c))
+
+	want := `<configuration description="Top apps &amp; games">
+    <option name="compatibility:include-filter" value="csuite_com.example.app" />
+    <test class="com.android.compatibility.testtype.AppLaunchTest" />
+</configuration>
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarshalGenerated_addsLicenseAndGeneratorNote(t *testing.T) {
+	got := string(MarshalGenerated(&Configuration{}, "test/app_compat/csuite/tools/top_apps"))
+
+	if !strings.Contains(got, "Android Open Source Project") ||
+		!strings.Contains(got, "auto-generated by test/app_compat/csuite/tools/top_apps.") {
+		t.Errorf("got %s", got)
+	}
+	if _, err := Parse([]byte(got)); err != nil {
+		t.Errorf("generated config does not parse: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// logcat_splitter splits a bulk logcat captured during a C-Suite run into
+// one file per tested package, so that the logs of a failure can be read
+// without the noise of every other app.
+//
+// Usage:
+//
+//	logcat_splitter [-host_log <file>] -o <dir> <logcat>
+//
+// By default, a package's segment starts at the "Launching app <package>"
+// line logged by the launch instrumentation and ends at the next one. With
+// -host_log, segments are instead the windows between the "Started testing
+// package" and "Completed testing package" lines that AppLaunchTest writes
+// to the Tradefed host log, matched against the logcat timestamps; this
+// needs a logcat in the threadtime format. Lines outside every segment are
+// written to other.txt.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const otherFile = "other.txt"
+
+var (
+	launchMarkerPattern = regexp.MustCompile(`AppCompatibility\s*(?:\(\s*\d+\))?: Launching app ([\w.]+)`)
+	hostLogPattern      = regexp.MustCompile(`^(\d\d-\d\d \d\d:\d\d:\d\d)(?:\.\d+)? [VDIWEF]/AppLaunchTest: (Started|Completed) testing package: ([\w.]+?)\.?$`)
+	timestampPattern    = regexp.MustCompile(`^(\d\d-\d\d \d\d:\d\d:\d\d\.\d\d\d)`)
+)
+
+// window is the time span of a package's test, as logcat timestamps of the
+// form "MM-DD HH:MM:SS.mmm", which sort chronologically within a year.
+type window struct {
+	pkg, start, end string
+}
+
+// segments holds the lines of each package in file order.
+type segments struct {
+	order []string
+	lines map[string][]string
+	other []string
+}
+
+func newSegments() *segments {
+	return &segments{lines: make(map[string][]string)}
+}
+
+func (s *segments) add(pkg, line string) {
+	if pkg == "" {
+		s.other = append(s.other, line)
+		return
+	}
+	if _, ok := s.lines[pkg]; !ok {
+		s.order = append(s.order, pkg)
+	}
+	s.lines[pkg] = append(s.lines[pkg], line)
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return s
+}
+
+// splitByMarkers assigns every line to the package launched last.
+func splitByMarkers(r io.Reader) (*segments, error) {
+	segs := newSegments()
+	current := ""
+	s := newScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if m := launchMarkerPattern.FindStringSubmatch(line); m != nil {
+			current = m[1]
+		}
+		segs.add(current, line)
+	}
+	return segs, s.Err()
+}
+
+// parseHostLog returns the test windows recorded in a Tradefed host log.
+// Host log timestamps have second precision, so windows are widened to the
+// whole seconds they start and end in.
+func parseHostLog(r io.Reader) ([]window, error) {
+	var windows []window
+	started := make(map[string]string)
+	s := newScanner(r)
+	for s.Scan() {
+		m := hostLogPattern.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+		ts, event, pkg := m[1], m[2], m[3]
+		if event == "Started" {
+			started[pkg] = ts + ".000"
+			continue
+		}
+		if start, ok := started[pkg]; ok {
+			windows = append(windows, window{pkg: pkg, start: start, end: ts + ".999"})
+			delete(started, pkg)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].start < windows[j].start })
+	return windows, s.Err()
+}
+
+// splitByWindows assigns every line to the package whose window contains its
+// timestamp. Lines without a timestamp, such as the continuation lines some
+// tools emit, follow the previous line.
+func splitByWindows(r io.Reader, windows []window) (*segments, error) {
+	segs := newSegments()
+	current := ""
+	s := newScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if m := timestampPattern.FindStringSubmatch(line); m != nil {
+			current = ""
+			for _, w := range windows {
+				if w.start <= m[1] && m[1] <= w.end {
+					current = w.pkg
+					break
+				}
+			}
+		}
+		segs.add(current, line)
+	}
+	return segs, s.Err()
+}
+
+func (s *segments) write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	files := map[string][]string{otherFile: s.other}
+	for _, pkg := range s.order {
+		files[pkg+".txt"] = s.lines[pkg]
+	}
+	for name, lines := range files {
+		if len(lines) == 0 {
+			continue
+		}
+		data := strings.Join(lines, "\n") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// split splits the logcat at logcatPath, using the windows of the host log
+// at hostLogPath if set, or the launch markers otherwise.
+func split(logcatPath, hostLogPath string) (*segments, error) {
+	logcat, err := os.Open(logcatPath)
+	if err != nil {
+		return nil, err
+	}
+	defer logcat.Close()
+	if hostLogPath == "" {
+		return splitByMarkers(logcat)
+	}
+
+	hostLog, err := os.Open(hostLogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer hostLog.Close()
+	windows, err := parseHostLog(hostLog)
+	if err != nil {
+		return nil, err
+	}
+	return splitByWindows(logcat, windows)
+}
+
+func main() {
+	hostLog := flag.String("host_log", "", "Tradefed host log to take the test windows from")
+	out := flag.String("o", "", "output directory")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-host_log <file>] -o <dir> <logcat>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *out == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	segs, err := split(flag.Arg(0), *hostLog)
+	if err == nil {
+		err = segs.write(*out)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, pkg := range segs.order {
+		fmt.Printf("%s: %d lines\n", pkg, len(segs.lines[pkg]))
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const logcat = `10-16 12:00:00.500  100  100 I ActivityManager: boot
+10-16 12:00:01.000  200  200 D AppCompatibility: Launching app com.example.a
+10-16 12:00:01.500  300  300 E AndroidRuntime: FATAL EXCEPTION: main
+10-16 12:00:03.000  200  200 D AppCompatibility: Launching app com.example.b
+10-16 12:00:03.200  400  400 I com.example.b: hello
+10-16 12:00:03.200  400  400 I com.example.b: second line
+10-16 12:00:06.000  100  100 I ActivityManager: idle
+`
+
+func TestSplitByMarkers(t *testing.T) {
+	segs, err := splitByMarkers(strings.NewReader(logcat))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"com.example.a", "com.example.b"}; !reflect.DeepEqual(segs.order, want) {
+		t.Errorf("got packages %v, want %v", segs.order, want)
+	}
+	if len(segs.lines["com.example.a"]) != 2 || len(segs.lines["com.example.b"]) != 4 || len(segs.other) != 1 {
+		t.Errorf("got %+v", segs)
+	}
+}
+
+func TestParseHostLog(t *testing.T) {
+	hostLog := `10-16 12:00:00 I/TestInvocation: Starting invocation
+10-16 12:00:01 D/AppLaunchTest: Started testing package: com.example.a.
+10-16 12:00:02 D/AppLaunchTest: Completed testing package: com.example.a
+10-16 12:00:03 D/AppLaunchTest: Started testing package: com.example.b.
+10-16 12:00:04 D/AppLaunchTest: Completed testing package: com.example.b
+10-16 12:00:05 D/AppLaunchTest: Started testing package: com.example.c.
+`
+
+	got, err := parseHostLog(strings.NewReader(hostLog))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []window{
+		{pkg: "com.example.a", start: "10-16 12:00:01.000", end: "10-16 12:00:02.999"},
+		{pkg: "com.example.b", start: "10-16 12:00:03.000", end: "10-16 12:00:04.999"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSplitByWindows(t *testing.T) {
+	windows := []window{
+		{pkg: "com.example.a", start: "10-16 12:00:01.000", end: "10-16 12:00:02.999"},
+		{pkg: "com.example.b", start: "10-16 12:00:03.000", end: "10-16 12:00:04.999"},
+	}
+
+	segs, err := splitByWindows(strings.NewReader(logcat+"continuation without timestamp\n"), windows)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs.lines["com.example.a"]) != 2 || len(segs.lines["com.example.b"]) != 3 || len(segs.other) != 3 {
+		t.Errorf("got %+v", segs)
+	}
+}
+
+func TestWrite_writesOneFilePerPackage(t *testing.T) {
+	segs := newSegments()
+	segs.add("com.example.a", "a1")
+	segs.add("", "x")
+	segs.add("com.example.a", "a2")
+	dir := filepath.Join(t.TempDir(), "out")
+
+	if err := segs.write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"com.example.a.txt": "a1\na2\n", otherFile: "x\n"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// module_preview prints the module config generated for a package, so that
+// template authors can check the output for specific apps before lab runs.
+//
+// Usage:
+//
+//	module_preview [-template <file>] <package>
+//
+// Without -template, the output is the AndroidTest.xml that
+// generate_module.py writes for the package. A template may use the
+// {package_name} and {module_name} placeholders, with {{ and }} for literal
+// braces, and must expand to a valid Tradefed config.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"android/test/app_compat/csuite/tools/internal/configtemplate"
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+)
+
+// defaultTemplate is the AndroidTest.xml written by
+// tools/script/generate_module.py; keep the two in sync.
+const defaultTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<!-- Copyright (C) 2020 The Android Open Source Project
+     Licensed under the Apache License, Version 2.0 (the "License");
+     you may not use this file except in compliance with the License.
+     You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+     Unless required by applicable law or agreed to in writing, software
+     distributed under the License is distributed on an "AS IS" BASIS,
+     WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+     See the License for the specific language governing permissions and
+     limitations under the License.
+-->
+<!-- This file was auto-generated by test/app_compat/csuite/tools/script/generate_module.py.
+     Do not edit manually.
+-->
+
+<configuration description="Tests the compatibility of apps">
+    <option name="config-descriptor:metadata" key="plan" value="csuite-launch"/>
+    <option name="package-name" value="{package_name}"/>
+    <target_preparer class="com.android.tradefed.targetprep.TestAppInstallSetup">
+        <option name="test-file-name" value="csuite-launch-instrumentation.apk"/>
+    </target_preparer>
+    <target_preparer class="com.android.compatibility.targetprep.AppSetupPreparer"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest"/>
+</configuration>
+`
+
+// preview expands template for packageName.
+func preview(template []byte, packageName string) ([]byte, error) {
+	if !packagelist.ValidPackageName(packageName) {
+		return nil, fmt.Errorf("invalid package name %q", packageName)
+	}
+	return configtemplate.Expand(template, map[string]string{
+		"package_name": packageName,
+		"module_name":  packagelist.ModuleName(packageName),
+	})
+}
+
+func main() {
+	templatePath := flag.String("template", "", "module config template; defaults to the generate_module.py template")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-template <file>] <package>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	template := []byte(defaultTemplate)
+	if *templatePath != "" {
+		data, err := os.ReadFile(*templatePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		template = data
+	}
+
+	config, err := preview(template, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(config)
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreview_defaultTemplate_matchesGenerateModule(t *testing.T) {
+	got, err := preview([]byte(defaultTemplate), "com.example.app")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The body written by generate_module.py's write_test_module.
+	want := `<configuration description="Tests the compatibility of apps">
+    <option name="config-descriptor:metadata" key="plan" value="csuite-launch"/>
+    <option name="package-name" value="com.example.app"/>
+    <target_preparer class="com.android.tradefed.targetprep.TestAppInstallSetup">
+        <option name="test-file-name" value="csuite-launch-instrumentation.apk"/>
+    </target_preparer>
+    <target_preparer class="com.android.compatibility.targetprep.AppSetupPreparer"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest"/>
+</configuration>
+`
+	if !strings.HasSuffix(string(got), "-->\n\n"+want) {
+		t.Errorf("got\n%s\nwant suffix\n%s", got, want)
+	}
+}
+
+func TestPreview_customTemplate(t *testing.T) {
+	template := `<configuration description="{module_name}"><option name="package-name" value="{package_name}" /></configuration>`
+
+	got, err := preview([]byte(template), "com.example.app")
+
+	want := `<configuration description="csuite_com.example.app"><option name="package-name" value="com.example.app" /></configuration>`
+	if err != nil || string(got) != want {
+		t.Errorf("got %s, %v", got, err)
+	}
+}
+
+func TestPreview_errors(t *testing.T) {
+	if _, err := preview([]byte(defaultTemplate), "not a package"); err == nil {
+		t.Error("invalid package name: preview() succeeded, want error")
+	}
+	if _, err := preview([]byte(`<configuration>{version}</configuration>`), "com.example.app"); err == nil {
+		t.Error("unknown placeholder: preview() succeeded, want error")
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// option_migrator rewrites deprecated option names and object classes in
+// checked-in Tradefed configs and templates according to a migration table,
+// so that a Tradefed upgrade does not need manual edits to every template.
+//
+// Usage:
+//
+//	option_migrator -table <file> [-n] <file or dir>...
+//
+// Each line of the table is a rule; empty lines and lines starting with #
+// are ignored:
+//
+//	class <old class> <new class>
+//	option <old name> <new name> [<class>]
+//
+// A class rule renames the class of every object (test, target_preparer,
+// etc.). An option rule renames options with that exact name; when a class
+// is given, only options set on objects of that class, under its old or new
+// name, are renamed. Directories are searched for .xml files, and only files
+// whose root element is <configuration> are rewritten. Only the renamed
+// attribute values change, so formatting and comments are kept.
+//
+// Every rename is printed as "path:line: old -> new". With -n, files are
+// not written, and option_migrator exits with status 1 if any file would
+// change.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const optionElement = "option"
+
+type optionRule struct {
+	old, new string
+	// class limits the rule to options of objects of that class.
+	class string
+}
+
+type table struct {
+	classes map[string]string
+	options []optionRule
+}
+
+func parseTable(r io.Reader) (*table, error) {
+	t := &table{classes: make(map[string]string)}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		switch {
+		case f[0] == "class" && len(f) == 3:
+			if _, ok := t.classes[f[1]]; ok {
+				return nil, fmt.Errorf("line %d: class %s is renamed twice", n, f[1])
+			}
+			t.classes[f[1]] = f[2]
+		case f[0] == "option" && (len(f) == 3 || len(f) == 4):
+			r := optionRule{old: f[1], new: f[2]}
+			if len(f) == 4 {
+				r.class = f[3]
+			}
+			t.options = append(t.options, r)
+		default:
+			return nil, fmt.Errorf("line %d: want \"class <old> <new>\" or \"option <old> <new> [<class>]\", got %q", n, line)
+		}
+	}
+	return t, s.Err()
+}
+
+// renameOption returns the new name of an option set on an object of class,
+// which is "" for options outside objects.
+func (t *table) renameOption(name, class string) (string, bool) {
+	newClass := t.classes[class]
+	for _, r := range t.options {
+		if r.old == name && (r.class == "" || r.class == class || (newClass != "" && r.class == newClass)) {
+			return r.new, true
+		}
+	}
+	return "", false
+}
+
+// edit replaces data[start:end] with value.
+type edit struct {
+	start, end int
+	old, value string
+}
+
+// attrValue returns the byte range of the value of attribute name, without
+// quotes, in a start tag.
+func attrValue(tag []byte, name string) (int, int, bool) {
+	re := regexp.MustCompile(`\s` + regexp.QuoteMeta(name) + `\s*=\s*("[^"]*"|'[^']*')`)
+	m := re.FindSubmatchIndex(tag)
+	if m == nil {
+		return 0, 0, false
+	}
+	return m[2] + 1, m[3] - 1, true
+}
+
+func attr(e xml.StartElement, name string) (string, bool) {
+	for _, a := range e.Attr {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// migrate returns the edits that apply t to a config. Configs whose root is
+// not <configuration> get no edits.
+func migrate(data []byte, t *table) ([]edit, error) {
+	var edits []edit
+	d := xml.NewDecoder(bytes.NewReader(data))
+	// classes holds the class of each open element, "" for elements that
+	// are not objects.
+	var classes []string
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(classes) == 0 && tok.Name.Local != tfconfig.RootElement {
+				return nil, nil
+			}
+			tag := data[start:d.InputOffset()]
+			rename := func(attrName, value string) {
+				if s, e, ok := attrValue(tag, attrName); ok {
+					edits = append(edits, edit{start: start + s, end: start + e, old: string(tag[s:e]), value: tfconfig.EscapeAttr(value)})
+				}
+			}
+			class, _ := attr(tok, "class")
+			if newClass, ok := t.classes[class]; ok {
+				rename("class", newClass)
+			}
+			if tok.Name.Local == optionElement {
+				name, _ := attr(tok, "name")
+				parent := ""
+				if len(classes) > 0 {
+					parent = classes[len(classes)-1]
+				}
+				if newName, ok := t.renameOption(name, parent); ok {
+					rename("name", newName)
+				}
+			}
+			classes = append(classes, class)
+		case xml.EndElement:
+			classes = classes[:len(classes)-1]
+		}
+	}
+	return edits, nil
+}
+
+func apply(data []byte, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var b bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		b.Write(data[last:e.start])
+		b.WriteString(e.value)
+		last = e.end
+	}
+	b.Write(data[last:])
+	return b.Bytes()
+}
+
+func lineAt(data []byte, offset int) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		err := filepath.WalkDir(arg, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if p == arg || filepath.Ext(p) == ".xml" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func main() {
+	tablePath := flag.String("table", "", "migration table")
+	dryRun := flag.Bool("n", false, "print the renames without writing files")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -table <file> [-n] <file or dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *tablePath == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*tablePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	t, err := parseTable(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *tablePath, err)
+		os.Exit(2)
+	}
+
+	files, err := collectFiles(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	changed := false
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		edits, err := migrate(data, t)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(2)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		changed = true
+		for _, e := range edits {
+			fmt.Printf("%s:%d: %s -> %s\n", path, lineAt(data, e.start), e.old, e.value)
+		}
+		if !*dryRun {
+			if err := os.WriteFile(path, apply(data, edits), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+	}
+	if *dryRun && changed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const migrations = `# Tradefed 2020 Q4 renames.
+class com.android.tradefed.targetprep.TestAppInstallSetup com.android.tradefed.targetprep.suite.SuiteApkInstaller
+option test-file-name apk-file-name com.android.tradefed.targetprep.suite.SuiteApkInstaller
+option retry-count launch-retries com.android.compatibility.testtype.AppLaunchTest
+option log-level-display console-log-level
+`
+
+func TestParseTable_rejectsMalformedRules(t *testing.T) {
+	for _, input := range []string{
+		"class a.B\n",
+		"rename a b\n",
+		"class a.B c.D\nclass a.B e.F\n",
+	} {
+		if _, err := parseTable(strings.NewReader(input)); err == nil {
+			t.Errorf("parseTable(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tbl, err := parseTable(strings.NewReader(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := `<?xml version="1.0" encoding="utf-8"?>
+<!-- test-file-name is kept in comments. -->
+<configuration description="Tests the compatibility of apps">
+    <option name="log-level-display" value="VERBOSE" />
+    <option name="test-file-name" value="top-level.apk" />
+    <target_preparer class="com.android.tradefed.targetprep.TestAppInstallSetup">
+        <option name='test-file-name'
+                value="csuite-launch-instrumentation.apk" />
+    </target_preparer>
+    <test class="com.android.compatibility.testtype.AppLaunchTest">
+        <option name="retry-count" value="3" />
+        <option name="package-name" value="com.example" />
+    </test>
+</configuration>
+`
+	want := `<?xml version="1.0" encoding="utf-8"?>
+<!-- test-file-name is kept in comments. -->
+<configuration description="Tests the compatibility of apps">
+    <option name="console-log-level" value="VERBOSE" />
+    <option name="test-file-name" value="top-level.apk" />
+    <target_preparer class="com.android.tradefed.targetprep.suite.SuiteApkInstaller">
+        <option name='apk-file-name'
+                value="csuite-launch-instrumentation.apk" />
+    </target_preparer>
+    <test class="com.android.compatibility.testtype.AppLaunchTest">
+        <option name="launch-retries" value="3" />
+        <option name="package-name" value="com.example" />
+    </test>
+</configuration>
+`
+
+	edits, err := migrate([]byte(config), tbl)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(apply([]byte(config), edits)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if len(edits) != 4 {
+		t.Errorf("got %d edits, want 4", len(edits))
+	}
+}
+
+func TestMigrate_skipsOtherXML(t *testing.T) {
+	tbl, err := parseTable(strings.NewReader(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edits, err := migrate([]byte(`<module><option name="log-level-display" /></module>`), tbl)
+
+	if err != nil || len(edits) != 0 {
+		t.Errorf("got %v, %v, want no edits", edits, err)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// pass_rate aggregates the results of many runs into pass-rate time series,
+// per plan and per app, for dashboards.
+//
+// Usage:
+//
+//	pass_rate [-window <duration>] [-format json|csv] [-o <file>] <result>...
+//
+// Each result is a test_result.xml, or a directory searched recursively for
+// test_result.xml files, such as the results directory of a lab host. Runs
+// are grouped by plan and by window of their start time; windows are
+// aligned to UTC, so 24h windows are calendar days. The pass rate of a
+// window is the fraction of the tests that passed, with skipped tests not
+// counted; an app tested on several ABIs or in several runs has one test
+// per ABI per run.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+// point is the pass rate of one window.
+type point struct {
+	Start    time.Time `json:"start"`
+	Runs     int       `json:"runs"`
+	Total    int       `json:"total"`
+	Passed   int       `json:"passed"`
+	PassRate float64   `json:"pass_rate"`
+}
+
+type series struct {
+	Plan string `json:"plan"`
+	// Package is empty for the series of a whole plan.
+	Package string  `json:"package,omitempty"`
+	Points  []point `json:"points"`
+}
+
+type report struct {
+	Window string   `json:"window"`
+	Plans  []series `json:"plans"`
+	Apps   []series `json:"apps"`
+}
+
+// findResults returns the result files at or under path.
+func findResults(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() == result.FileName {
+			files = append(files, p)
+		}
+		return err
+	})
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("%s: no %s found", path, result.FileName)
+	}
+	return files, err
+}
+
+type seriesKey struct {
+	plan, pkg string
+}
+
+type accumulator map[seriesKey]map[time.Time]*point
+
+func (a accumulator) add(key seriesKey, start time.Time, runs, total, passed int) {
+	if a[key] == nil {
+		a[key] = make(map[time.Time]*point)
+	}
+	p := a[key][start]
+	if p == nil {
+		p = &point{Start: start}
+		a[key][start] = p
+	}
+	p.Runs += runs
+	p.Total += total
+	p.Passed += passed
+}
+
+func (a accumulator) series() []series {
+	var all []series
+	for key, points := range a {
+		s := series{Plan: key.plan, Package: key.pkg}
+		for _, p := range points {
+			if p.Total > 0 {
+				p.PassRate = float64(p.Passed) / float64(p.Total)
+			}
+			s.Points = append(s.Points, *p)
+		}
+		sort.Slice(s.Points, func(i, j int) bool { return s.Points[i].Start.Before(s.Points[j].Start) })
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Plan != all[j].Plan {
+			return all[i].Plan < all[j].Plan
+		}
+		return all[i].Package < all[j].Package
+	})
+	return all
+}
+
+// aggregate groups runs into windows of the given length.
+func aggregate(runs []*result.Result, window time.Duration) *report {
+	plans, apps := make(accumulator), make(accumulator)
+	for _, r := range runs {
+		start := time.UnixMilli(r.Start).UTC().Truncate(window)
+		type counts struct{ total, passed int }
+		perApp := make(map[string]*counts)
+		var all counts
+		for _, row := range r.Rows() {
+			if row.Outcome == result.Skip {
+				continue
+			}
+			c := perApp[row.Package]
+			if c == nil {
+				c = &counts{}
+				perApp[row.Package] = c
+			}
+			c.total++
+			all.total++
+			if row.Outcome == result.Pass {
+				c.passed++
+				all.passed++
+			}
+		}
+		plans.add(seriesKey{plan: r.SuitePlan}, start, 1, all.total, all.passed)
+		for pkg, c := range perApp {
+			apps.add(seriesKey{plan: r.SuitePlan, pkg: pkg}, start, 1, c.total, c.passed)
+		}
+	}
+	return &report{Window: window.String(), Plans: plans.series(), Apps: apps.series()}
+}
+
+func (r *report) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		if r.Plans == nil {
+			r.Plans = []series{}
+		}
+		if r.Apps == nil {
+			r.Apps = []series{}
+		}
+		return e.Encode(r)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"plan", "package", "window_start", "runs", "total", "passed", "pass_rate"})
+		for _, s := range append(append([]series(nil), r.Plans...), r.Apps...) {
+			for _, p := range s.Points {
+				cw.Write([]string{
+					s.Plan, s.Package, p.Start.Format(time.RFC3339),
+					strconv.Itoa(p.Runs), strconv.Itoa(p.Total), strconv.Itoa(p.Passed),
+					strconv.FormatFloat(p.PassRate, 'f', 4, 64),
+				})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	window := flag.Duration("window", 24*time.Hour, "length of the aggregation windows")
+	format := flag.String("format", "json", "output format: json or csv")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-window <duration>] [-format json|csv] [-o <file>] <result>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *window <= 0 || (*format != "json" && *format != "csv") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var runs []*result.Result
+	for _, arg := range flag.Args() {
+		files, err := findResults(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		for _, f := range files {
+			r, err := result.ParseFile(f)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			if r.Start == 0 {
+				fmt.Fprintf(os.Stderr, "%s: no start time\n", f)
+				os.Exit(2)
+			}
+			runs = append(runs, r)
+		}
+	}
+
+	r := aggregate(runs, *window)
+	if *out == "" {
+		if err := r.write(os.Stdout, *format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	err = r.write(f, *format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+func run(plan string, start time.Time, outcomes map[string]string) *result.Result {
+	r := &result.Result{SuitePlan: plan, Start: start.UnixMilli()}
+	for pkg, outcome := range outcomes {
+		r.Modules = append(r.Modules, result.Module{
+			Name: "csuite_" + pkg, ABI: "arm64-v8a", Done: true,
+			TestCases: []result.TestCase{{
+				Name:  "com.android.compatibility.testtype.AppLaunchTest",
+				Tests: []result.Test{{Name: pkg, Result: outcome}},
+			}},
+		})
+	}
+	return r
+}
+
+func TestAggregate(t *testing.T) {
+	day := time.Date(2020, 10, 16, 0, 0, 0, 0, time.UTC)
+	runs := []*result.Result{
+		run("launch", day.Add(2*time.Hour), map[string]string{"com.example.a": "pass", "com.example.b": "fail"}),
+		run("launch", day.Add(20*time.Hour), map[string]string{"com.example.a": "pass", "com.example.b": "pass"}),
+		run("launch", day.Add(26*time.Hour), map[string]string{"com.example.a": "fail", "com.example.b": "IGNORED"}),
+	}
+
+	got := aggregate(runs, 24*time.Hour)
+
+	next := day.Add(24 * time.Hour)
+	want := &report{
+		Window: "24h0m0s",
+		Plans: []series{{Plan: "launch", Points: []point{
+			{Start: day, Runs: 2, Total: 4, Passed: 3, PassRate: 0.75},
+			{Start: next, Runs: 1, Total: 1, Passed: 0, PassRate: 0},
+		}}},
+		Apps: []series{
+			{Plan: "launch", Package: "com.example.a", Points: []point{
+				{Start: day, Runs: 2, Total: 2, Passed: 2, PassRate: 1},
+				{Start: next, Runs: 1, Total: 1, Passed: 0, PassRate: 0},
+			}},
+			{Plan: "launch", Package: "com.example.b", Points: []point{
+				{Start: day, Runs: 2, Total: 2, Passed: 1, PassRate: 0.5},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v,\nwant %+v", got, want)
+	}
+}
+
+func TestWrite_csv(t *testing.T) {
+	day := time.Date(2020, 10, 16, 0, 0, 0, 0, time.UTC)
+	r := aggregate([]*result.Result{run("launch", day, map[string]string{"com.example.a": "pass"})}, 24*time.Hour)
+	var b strings.Builder
+
+	if err := r.write(&b, "csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `plan,package,window_start,runs,total,passed,pass_rate
+launch,,2020-10-16T00:00:00Z,1,1,1,1.0000
+launch,com.example.a,2020-10-16T00:00:00Z,1,1,1,1.0000
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFindResults(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"2020.10.16_01.00.00/test_result.xml", "2020.10.17_01.00.00/test_result.xml", "logs/host_log.txt"} {
+		path := filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := findResults(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("got %v, want the two result files", got)
+	}
+	if _, err := findResults(filepath.Join(dir, "logs")); err == nil {
+		t.Error("got no error for a directory without results")
+	}
+}
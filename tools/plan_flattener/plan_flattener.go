@@ -0,0 +1,258 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// plan_flattener resolves every <include> and <template-include> of a plan
+// into a single self-contained config, to show what the harness actually
+// runs or to ship a plan on its own.
+//
+// Usage:
+//
+//	plan_flattener [-with <jar or dir>]... [-template <name>=<config>]... [-o <file>] <suite zip or dir> <plan>
+//
+// Includes are resolved by name in the suite and in the configs from -with,
+// e.g. tradefed.jar for the configs C-Suite includes from Tradefed.
+// Template includes use the config given with -template, or their default.
+// The contents of included configs come before the including config's own
+// options and objects, in include order, with template includes after
+// includes. The description of the plan is kept.
+//
+// The output only holds what the config model of the tools holds: includes,
+// template includes, options, and objects with a class and options. A config
+// with other elements or attributes, e.g. an <object> nested in a target
+// preparer, is rejected rather than flattened into a config that is not
+// equivalent to it.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+// attributes lists the attributes kept for each kind of element; objects are
+// listed under "".
+var attributes = map[string][]string{
+	"configuration":    {"description"},
+	"include":          {"name"},
+	"template-include": {"name", "default"},
+	"option":           {"name", "key", "value"},
+	"":                 {"class"},
+}
+
+// checkSupported returns an error if data has elements or attributes that
+// tfconfig.Configuration does not keep.
+func checkSupported(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	// path holds the names of the open elements.
+	var path []string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name := tok.Name.Local
+			// kind is the key of name in attributes.
+			kind := name
+			switch {
+			case len(path) == 0:
+			case len(path) == 1 && isObject(name):
+				kind = ""
+			case len(path) == 1:
+			case len(path) == 2 && isObject(path[1]) && name == "option":
+			default:
+				return fmt.Errorf("<%s> in <%s> is not supported", name, strings.Join(path, "><"))
+			}
+			for _, a := range tok.Attr {
+				if !contains(attributes[kind], a.Name.Local) || a.Name.Space != "" {
+					return fmt.Errorf("attribute %s of <%s> is not supported", a.Name.Local, name)
+				}
+			}
+			path = append(path, name)
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+}
+
+// isObject reports whether a child of the root element named name is an
+// object.
+func isObject(name string) bool {
+	return name == "configuration" || attributes[name] == nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+type flattener struct {
+	suite     *suite.Suite
+	templates map[string]string
+	// used records the template names that were resolved.
+	used map[string]bool
+}
+
+// flatten returns the named config with its includes resolved.
+func flatten(s *suite.Suite, name string, templates map[string]string) (*tfconfig.Configuration, error) {
+	f := &flattener{suite: s, templates: templates, used: make(map[string]bool)}
+	c, err := f.flatten(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	for t := range templates {
+		if !f.used[t] {
+			return nil, fmt.Errorf("template %q is not used by %s", t, name)
+		}
+	}
+	return c, nil
+}
+
+// flatten resolves name, where chain lists the configs including it.
+func (f *flattener) flatten(name string, chain []string) (*tfconfig.Configuration, error) {
+	for _, n := range chain {
+		if n == name {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), name)
+		}
+	}
+	chain = append(chain, name)
+	c, ok := f.suite.Configs[name]
+	if !ok {
+		if len(chain) == 1 {
+			return nil, fmt.Errorf("config %q not found", name)
+		}
+		return nil, fmt.Errorf("%s: include %q does not resolve to a config", strings.Join(chain[:len(chain)-1], " -> "), name)
+	}
+	parsed, err := tfconfig.Parse(c.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", c.Path, err)
+	}
+	if err := checkSupported(c.Data); err != nil {
+		return nil, fmt.Errorf("%s: %v", c.Path, err)
+	}
+
+	flat := &tfconfig.Configuration{Description: parsed.Description}
+	var included []string
+	for _, i := range parsed.Includes {
+		included = append(included, i.Name)
+	}
+	for _, t := range parsed.TemplateIncludes {
+		target, ok := f.templates[t.Name]
+		if ok {
+			f.used[t.Name] = true
+		} else {
+			target = t.Default
+		}
+		if target == "" {
+			return nil, fmt.Errorf("%s: template-include %q has no default; set it with -template", c.Path, t.Name)
+		}
+		included = append(included, target)
+	}
+	for _, i := range included {
+		sub, err := f.flatten(i, chain)
+		if err != nil {
+			return nil, err
+		}
+		flat.Options = append(flat.Options, sub.Options...)
+		flat.Objects = append(flat.Objects, sub.Objects...)
+	}
+	flat.Options = append(flat.Options, parsed.Options...)
+	flat.Objects = append(flat.Objects, parsed.Objects...)
+	return flat, nil
+}
+
+func main() {
+	var with, templateFlags flagutil.StringList
+	flag.Var(&with, "with", "jar or directory whose configs are only used to resolve includes; may be repeated")
+	flag.Var(&templateFlags, "template", "config to use for a template-include, as <name>=<config>; may be repeated")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-with <jar or dir>]... [-template <name>=<config>]... [-o <file>] <suite zip or dir> <plan>\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	templates := make(map[string]string)
+	for _, t := range templateFlags {
+		name, config, ok := strings.Cut(t, "=")
+		if !ok || name == "" || config == "" {
+			fmt.Fprintf(os.Stderr, "invalid -template %q, want <name>=<config>\n", t)
+			os.Exit(2)
+		}
+		templates[name] = config
+	}
+
+	s, err := suite.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, p := range with {
+		reference, err := suite.Open(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		s.Merge(reference)
+	}
+
+	c, err := flatten(s, flag.Arg(1), templates)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var f *os.File
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		w = f
+	}
+	_, err = w.Write(tfconfig.MarshalGenerated(c, "test/app_compat/csuite/tools/plan_flattener"))
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func newSuite(configs map[string]string) *suite.Suite {
+	s := suite.New()
+	for name, data := range configs {
+		s.Configs[name] = &suite.Config{Name: name, Path: name + ".xml", Data: []byte(data)}
+	}
+	return s
+}
+
+var configs = map[string]string{
+	"launch": `<configuration description="C-Suite Launch">
+  <include name="csuite-base" />
+  <option name="plan" value="launch" />
+</configuration>`,
+	"csuite-base": `<configuration description="CSuite Main Test Plan">
+  <include name="everything" />
+  <device_recovery class="com.android.tradefed.device.WaitDeviceRecovery" />
+  <option name="enable-root" value="true" />
+  <template-include name="reporters" default="basic-reporters" />
+  <result_reporter class="com.android.compatibility.common.tradefed.result.ConsoleReporter" />
+</configuration>`,
+	"everything":      `<configuration><test class="com.android.tradefed.testtype.suite.TfSuiteRunner" /></configuration>`,
+	"basic-reporters": `<configuration><result_reporter class="com.android.tradefed.result.TextResultReporter" /></configuration>`,
+	"json-reporters":  `<configuration><result_reporter class="com.android.tradefed.result.JsonReporter" /></configuration>`,
+}
+
+func classes(c *tfconfig.Configuration) []string {
+	var names []string
+	for _, o := range c.Objects {
+		names = append(names, o.Type()+" "+o.Class)
+	}
+	return names
+}
+
+func TestFlatten(t *testing.T) {
+	c, err := flatten(newSuite(configs), "launch", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Description != "C-Suite Launch" || len(c.Includes) != 0 || len(c.TemplateIncludes) != 0 {
+		t.Errorf("got %+v", c)
+	}
+	wantOptions := []tfconfig.Option{{Name: "enable-root", Value: "true"}, {Name: "plan", Value: "launch"}}
+	if !reflect.DeepEqual(c.Options, wantOptions) {
+		t.Errorf("got options %+v, want %+v", c.Options, wantOptions)
+	}
+	wantObjects := []string{
+		"test com.android.tradefed.testtype.suite.TfSuiteRunner",
+		"result_reporter com.android.tradefed.result.TextResultReporter",
+		"device_recovery com.android.tradefed.device.WaitDeviceRecovery",
+		"result_reporter com.android.compatibility.common.tradefed.result.ConsoleReporter",
+	}
+	if got := classes(c); !reflect.DeepEqual(got, wantObjects) {
+		t.Errorf("got objects %q, want %q", got, wantObjects)
+	}
+}
+
+func TestFlatten_template(t *testing.T) {
+	c, err := flatten(newSuite(configs), "launch", map[string]string{"reporters": "json-reporters"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := classes(c)[1]; got != "result_reporter com.android.tradefed.result.JsonReporter" {
+		t.Errorf("got %q for the template-include", got)
+	}
+}
+
+func TestFlatten_errors(t *testing.T) {
+	for _, tc := range []struct {
+		configs   map[string]string
+		templates map[string]string
+		want      string
+	}{
+		{configs, map[string]string{"unknown": "x"}, `template "unknown" is not used`},
+		{map[string]string{"launch": `<configuration><include name="missing" /></configuration>`}, nil,
+			`launch: include "missing" does not resolve`},
+		{map[string]string{
+			"launch": `<configuration><include name="a" /></configuration>`,
+			"a":      `<configuration><include name="launch" /></configuration>`,
+		}, nil, "include cycle: launch -> a -> launch"},
+		{map[string]string{"launch": `<configuration><template-include name="t" /></configuration>`}, nil,
+			`template-include "t" has no default`},
+		{map[string]string{"launch": `<configuration>
+  <target_preparer class="Preparer"><object type="x" class="Object" /></target_preparer>
+</configuration>`}, nil, "launch.xml: <object> in <configuration><target_preparer> is not supported"},
+		{map[string]string{"launch": `<configuration><test class="Test" timeout="10" /></configuration>`}, nil,
+			"launch.xml: attribute timeout of <test> is not supported"},
+	} {
+		_, err := flatten(newSuite(tc.configs), "launch", tc.templates)
+
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("got error %v, want one containing %q", err, tc.want)
+		}
+	}
+}
+
+func TestCheckSupported_modelElements_returnsNil(t *testing.T) {
+	data := `<configuration description="d">
+  <include name="a" />
+  <template-include name="t" default="b" />
+  <option name="o" key="k" value="v" />
+  <target_preparer class="Preparer"><option name="o" value="v" /></target_preparer>
+</configuration>`
+
+	if err := checkSupported([]byte(data)); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
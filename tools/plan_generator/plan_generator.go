@@ -0,0 +1,94 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// plan_generator expands a plan template into a plan config, so that plans
+// can be previewed and checked without running a full build.
+//
+// Usage:
+//
+//	plan_generator -template <file> -plan <name> [-var <name>=<value>]... [-o <file>]
+//
+// Placeholders are written {name}, as in the templates of
+// generate_module.py, and {{ and }} stand for literal braces. The plan name
+// is the value of {plan}. Every placeholder must have a value, and the
+// expanded plan must be a valid Tradefed config.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"android/test/app_compat/csuite/tools/internal/configtemplate"
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+)
+
+// planVars returns the values of the placeholders of a plan named plan.
+func planVars(plan string, assignments []string) (map[string]string, error) {
+	vars := map[string]string{"plan": plan}
+	for _, a := range assignments {
+		name, value, err := configtemplate.ParseVar(a)
+		if err != nil {
+			return nil, err
+		}
+		if name == "plan" {
+			return nil, fmt.Errorf("set the plan name with -plan, not -var")
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+func main() {
+	var assignments flagutil.StringList
+	templatePath := flag.String("template", "", "plan template")
+	plan := flag.String("plan", "", "plan name, the value of {plan}")
+	flag.Var(&assignments, "var", "placeholder value as name=value; may be repeated")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s -template <file> -plan <name> [-var <name>=<value>]... [-o <file>]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *templatePath == "" || *plan == "" || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	vars, err := planVars(*plan, assignments)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	template, err := os.ReadFile(*templatePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	config, err := configtemplate.Expand(template, vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *templatePath, err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(config)
+		return
+	}
+	if err := os.WriteFile(*out, config, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanVars(t *testing.T) {
+	got, err := planVars("top-social", []string{"filter=csuite_com.example.a", "empty="})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"plan": "top-social", "filter": "csuite_com.example.a", "empty": ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlanVars_invalidAssignments_returnError(t *testing.T) {
+	for _, a := range []string{"novalue", "=value", "bad-name=x", "plan=other"} {
+		if _, err := planVars("launch", []string{a}); err == nil {
+			t.Errorf("planVars(%q) succeeded, want error", a)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// plan_validator checks the plans and module configs of a built C-Suite, so
+// that broken configs are caught by release pipelines instead of at run time.
+//
+// Usage:
+//
+//	plan_validator [-with <jar or dir>]... <suite zip or dir>
+//
+// Every plan and module config must be well-formed XML with a
+// <configuration> root with no unexpanded template placeholders, i.e. the
+// {package_name} and {module_name} of module templates or the {plan} of plan
+// templates; other braces are literal text of the config. Every <include>
+// and <template-include> default must resolve to a config, and every
+// test-file-name option must name a file in the testcases directory when the
+// suite has one. Test files are matched by name anywhere under testcases, as
+// Tradefed finds them. Configs from -with are only used to resolve includes,
+// e.g. tradefed.jar when validating a checked-in config directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/configtemplate"
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const testFileNameOption = "test-file-name"
+
+// templateKeys are the placeholders of the module templates expanded by
+// generate_module.py and module_preview, and of the plan templates expanded
+// by plan_generator.
+var templateKeys = map[string]bool{
+	"package_name": true,
+	"module_name":  true,
+	"plan":         true,
+}
+
+type problem struct {
+	path    string
+	message string
+}
+
+func (p problem) String() string {
+	return p.path + ": " + p.message
+}
+
+// validate returns the problems found in the plans and module configs of s,
+// sorted by path.
+func validate(s *suite.Suite) []problem {
+	var problems []problem
+	configs := append(s.Plans(), s.SortedModules()...)
+	for _, c := range configs {
+		for _, message := range validateConfig(s, c) {
+			problems = append(problems, problem{c.Path, message})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].path < problems[j].path })
+	return problems
+}
+
+func validateConfig(s *suite.Suite, c *suite.Config) []string {
+	parsed, err := tfconfig.Parse(c.Data)
+	if err != nil {
+		return []string{fmt.Sprintf("not a valid config: %v", err)}
+	}
+	var messages []string
+	var names []string
+	for _, name := range configtemplate.Placeholders(c.Data) {
+		if templateKeys[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		messages = append(messages, fmt.Sprintf("unexpanded template placeholders {%s}", strings.Join(names, "}, {")))
+	}
+	for _, include := range parsed.Includes {
+		if _, ok := s.Configs[include.Name]; !ok {
+			messages = append(messages, fmt.Sprintf("include %q does not resolve to a config", include.Name))
+		}
+	}
+	for _, include := range parsed.TemplateIncludes {
+		if include.Default == "" {
+			continue
+		}
+		if _, ok := s.Configs[include.Default]; !ok {
+			messages = append(messages, fmt.Sprintf(
+				"default %q of template-include %q does not resolve to a config",
+				include.Default, include.Name))
+		}
+	}
+	if len(s.TestFiles) > 0 {
+		for _, name := range tfconfig.OptionValues(parsed.AllOptions(), testFileNameOption) {
+			if !s.HasTestFile(name) {
+				messages = append(messages, fmt.Sprintf("%s %q not found in testcases", testFileNameOption, name))
+			}
+		}
+	}
+	return messages
+}
+
+func main() {
+	var with flagutil.StringList
+	flag.Var(&with, "with", "jar or directory whose configs are only used to resolve includes; may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-with <jar or dir>]... <suite zip or dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := suite.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, p := range with {
+		reference, err := suite.Open(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		s.Merge(reference)
+	}
+
+	problems := validate(s)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
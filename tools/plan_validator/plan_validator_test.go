@@ -0,0 +1,147 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+const moduleConfig = `<configuration description="Tests the compatibility of apps">
+    <option name="package-name" value="com.example.app"/>
+    <target_preparer class="com.android.tradefed.targetprep.TestAppInstallSetup">
+        <option name="test-file-name" value="csuite-launch-instrumentation.apk"/>
+    </target_preparer>
+</configuration>`
+
+func TestValidate_validSuite_returnsNoProblems(t *testing.T) {
+	s := suite.New()
+	addPlan(s, "launch", `<configuration><include name="csuite-base" /></configuration>`)
+	addPlan(s, "csuite-base", `<configuration><template-include name="reporters" default="basic-reporters" /></configuration>`)
+	s.Configs["basic-reporters"] = &suite.Config{Name: "basic-reporters", Data: []byte(`<configuration />`)}
+	addModule(s, "csuite_com.example.app", moduleConfig)
+	s.TestFiles["csuite-launch-instrumentation.apk"] = true
+
+	problems := validate(s)
+
+	if len(problems) != 0 {
+		t.Errorf("got problems %v, want none", problems)
+	}
+}
+
+func TestValidate_malformedPlan_reportsProblem(t *testing.T) {
+	s := suite.New()
+	addPlan(s, "launch", `<configuration><option name="plan" value="launch"></configuration>`)
+
+	problems := validate(s)
+
+	assertProblemPaths(t, problems, "launch.xml")
+}
+
+func TestValidate_unresolvedIncludes_reportsProblems(t *testing.T) {
+	s := suite.New()
+	addPlan(s, "launch", `<configuration>
+  <include name="missing-base" />
+  <template-include name="reporters" default="missing-reporters" />
+  <template-include name="preparers" />
+</configuration>`)
+
+	problems := validate(s)
+
+	assertProblemPaths(t, problems, "launch.xml", "launch.xml")
+}
+
+func TestValidate_missingTestFile_reportsProblem(t *testing.T) {
+	s := suite.New()
+	addModule(s, "csuite_com.example.app", moduleConfig)
+	s.TestFiles["csuite_com.example.app.config"] = true
+
+	problems := validate(s)
+
+	assertProblemPaths(t, problems, "csuite_com.example.app.config")
+}
+
+func TestValidate_testFileInModuleDirectory_returnsNoProblems(t *testing.T) {
+	s := suite.New()
+	addModule(s, "csuite_com.example.app", moduleConfig)
+	s.TestFiles["csuite_com.example.app.config"] = true
+	s.TestFiles["csuite_com.example.app/csuite-launch-instrumentation.apk"] = true
+
+	problems := validate(s)
+
+	if len(problems) != 0 {
+		t.Errorf("got problems %v, want none", problems)
+	}
+}
+
+func TestValidate_unexpandedPlaceholders_reportsProblem(t *testing.T) {
+	s := suite.New()
+	addModule(s, "csuite_com.example.app", `<configuration>
+    <option name="package-name" value="{package_name}"/>
+    <option name="regex" value="a{{2}}"/>
+</configuration>`)
+
+	problems := validate(s)
+
+	assertProblemPaths(t, problems, "csuite_com.example.app.config")
+	if len(problems) == 1 && !strings.Contains(problems[0].message, "{package_name}") {
+		t.Errorf("got %q, want it to name {package_name}", problems[0].message)
+	}
+}
+
+func TestValidate_literalBraces_returnsNoProblems(t *testing.T) {
+	s := suite.New()
+	addModule(s, "csuite_com.example.app", `<configuration>
+    <option name="package-name" value="com.example.app"/>
+    <option name="args" value="{timeout} {x,y}"/>
+</configuration>`)
+
+	if problems := validate(s); len(problems) != 0 {
+		t.Errorf("got problems %v, want none", problems)
+	}
+}
+
+func TestValidate_noTestcases_skipsTestFileCheck(t *testing.T) {
+	s := suite.New()
+	addPlan(s, "module", moduleConfig)
+
+	problems := validate(s)
+
+	if len(problems) != 0 {
+		t.Errorf("got problems %v, want none", problems)
+	}
+}
+
+func addPlan(s *suite.Suite, name, content string) {
+	s.Configs[name] = &suite.Config{Name: name, Path: name + ".xml", Data: []byte(content), Plan: true}
+}
+
+func addModule(s *suite.Suite, name, content string) {
+	s.Modules[name] = &suite.Config{Name: name, Path: name + ".config", Data: []byte(content), Plan: true}
+}
+
+func assertProblemPaths(t *testing.T, problems []problem, paths ...string) {
+	t.Helper()
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.path)
+	}
+	if !reflect.DeepEqual(got, paths) {
+		t.Errorf("got problems %v, want problems for %v", problems, paths)
+	}
+}
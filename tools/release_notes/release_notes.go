@@ -0,0 +1,263 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// release_notes summarizes the differences between two builds of C-Suite
+// as plain-text release notes for suite release emails: the plans added,
+// removed and changed, the apps added to and removed from the suite, and the
+// option changes in module configs.
+//
+// Usage:
+//
+//	release_notes [-old_label <name>] [-new_label <name>] [-o <file>] <old suite zip or dir> <new suite zip or dir>
+//
+// The labels name the builds in the notes and default to the base names of
+// the suite paths. Modules changed in the same way, e.g. every generated
+// module after a template change, are listed together under one set of
+// changes. The underlying comparison is the one suite_diff reports.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/suitediff"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+// moduleGroup is a set of modules that changed in the same way.
+type moduleGroup struct {
+	modules []string
+	changes []suitediff.Change
+}
+
+type notes struct {
+	oldLabel, newLabel string
+	addedPlans         []*suite.Config
+	removedPlans       []string
+	changedPlans       []suitediff.ConfigDiff
+	// addedApps and removedApps are package names of generated modules;
+	// other modules are listed by module name.
+	addedApps, removedApps       []string
+	addedModules, removedModules []string
+	changedModules               []moduleGroup
+}
+
+func build(old, new *suite.Suite, oldLabel, newLabel string) *notes {
+	d := suitediff.Compare(old, new)
+	n := &notes{oldLabel: oldLabel, newLabel: newLabel}
+	for _, p := range d.Plans {
+		switch p.Status {
+		case suitediff.Added:
+			n.addedPlans = append(n.addedPlans, new.Configs[p.Name])
+		case suitediff.Removed:
+			n.removedPlans = append(n.removedPlans, p.Name)
+		default:
+			n.changedPlans = append(n.changedPlans, p)
+		}
+	}
+
+	groups := make(map[string]*moduleGroup)
+	for _, m := range d.Modules {
+		pkg, isApp := packagelist.PackageName(m.Name)
+		switch {
+		case m.Status == suitediff.Added && isApp:
+			n.addedApps = append(n.addedApps, pkg)
+		case m.Status == suitediff.Removed && isApp:
+			n.removedApps = append(n.removedApps, pkg)
+		case m.Status == suitediff.Added:
+			n.addedModules = append(n.addedModules, m.Name)
+		case m.Status == suitediff.Removed:
+			n.removedModules = append(n.removedModules, m.Name)
+		default:
+			key := changesKey(m.Changes)
+			if groups[key] == nil {
+				groups[key] = &moduleGroup{changes: m.Changes}
+			}
+			groups[key].modules = append(groups[key].modules, m.Name)
+		}
+	}
+	for _, g := range groups {
+		n.changedModules = append(n.changedModules, *g)
+	}
+	sort.Slice(n.changedModules, func(i, j int) bool {
+		a, b := n.changedModules[i], n.changedModules[j]
+		if len(a.modules) != len(b.modules) {
+			return len(a.modules) > len(b.modules)
+		}
+		return a.modules[0] < b.modules[0]
+	})
+	return n
+}
+
+func changesKey(changes []suitediff.Change) string {
+	var lines []string
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// description returns the description of a plan, or "" if it has none or
+// does not parse.
+func description(c *suite.Config) string {
+	conf, err := tfconfig.Parse(c.Data)
+	if err != nil {
+		return ""
+	}
+	return conf.Description
+}
+
+func (n *notes) empty() bool {
+	return len(n.addedPlans)+len(n.removedPlans)+len(n.changedPlans)+len(n.addedApps)+len(n.removedApps)+
+		len(n.addedModules)+len(n.removedModules)+len(n.changedModules) == 0
+}
+
+func (n *notes) write(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "C-Suite changes from %s to %s\n", n.oldLabel, n.newLabel)
+	if n.empty() {
+		b.WriteString("\nNo plan, app or module changes.\n")
+		_, err := w.Write(b.Bytes())
+		return err
+	}
+
+	section := func(title string, count int) bool {
+		if count == 0 {
+			return false
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", title, count)
+		return true
+	}
+	if section("New plans", len(n.addedPlans)) {
+		for _, p := range n.addedPlans {
+			if desc := description(p); desc != "" {
+				fmt.Fprintf(&b, "  * %s: %s\n", p.Name, desc)
+			} else {
+				fmt.Fprintf(&b, "  * %s\n", p.Name)
+			}
+		}
+	}
+	if section("Removed plans", len(n.removedPlans)) {
+		writeList(&b, n.removedPlans)
+	}
+	if section("Changed plans", len(n.changedPlans)) {
+		for _, p := range n.changedPlans {
+			fmt.Fprintf(&b, "  * %s\n", p.Name)
+			writeChanges(&b, p.Changes)
+		}
+	}
+	if section("Added apps", len(n.addedApps)) {
+		writeList(&b, n.addedApps)
+	}
+	if section("Removed apps", len(n.removedApps)) {
+		writeList(&b, n.removedApps)
+	}
+	if section("Added modules", len(n.addedModules)) {
+		writeList(&b, n.addedModules)
+	}
+	if section("Removed modules", len(n.removedModules)) {
+		writeList(&b, n.removedModules)
+	}
+	changed := 0
+	for _, g := range n.changedModules {
+		changed += len(g.modules)
+	}
+	if section("Changed modules", changed) {
+		for _, g := range n.changedModules {
+			if len(g.modules) == 1 {
+				fmt.Fprintf(&b, "  * %s\n", g.modules[0])
+			} else {
+				fmt.Fprintf(&b, "  * %d modules: %s\n", len(g.modules), strings.Join(g.modules, ", "))
+			}
+			writeChanges(&b, g.changes)
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeList(b *bytes.Buffer, names []string) {
+	for _, name := range names {
+		fmt.Fprintf(b, "  * %s\n", name)
+	}
+}
+
+func writeChanges(b *bytes.Buffer, changes []suitediff.Change) {
+	for _, c := range changes {
+		fmt.Fprintf(b, "      %s\n", c)
+	}
+}
+
+func main() {
+	oldLabel := flag.String("old_label", "", "name of the old build; defaults to the base name of its path")
+	newLabel := flag.String("new_label", "", "name of the new build; defaults to the base name of its path")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-old_label <name>] [-new_label <name>] [-o <file>] <old suite zip or dir> <new suite zip or dir>\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var suites [2]*suite.Suite
+	for i := range suites {
+		s, err := suite.Open(flag.Arg(i))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		suites[i] = s
+	}
+	if *oldLabel == "" {
+		*oldLabel = filepath.Base(flag.Arg(0))
+	}
+	if *newLabel == "" {
+		*newLabel = filepath.Base(flag.Arg(1))
+	}
+
+	var f *os.File
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		w = f
+	}
+	err := build(suites[0], suites[1], *oldLabel, *newLabel).write(w)
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+func newSuite(plans, modules map[string]string) *suite.Suite {
+	s := suite.New()
+	for name, data := range plans {
+		s.Configs[name] = &suite.Config{Name: name, Data: []byte(data), Plan: true}
+	}
+	for name, data := range modules {
+		s.Modules[name] = &suite.Config{Name: name, Data: []byte(data)}
+	}
+	return s
+}
+
+func TestWrite(t *testing.T) {
+	module := `<configuration><option name="package-name" value="%s" /></configuration>`
+	changedModule := `<configuration><option name="package-name" value="%s" /><option name="retry" value="1" /></configuration>`
+	old := newSuite(map[string]string{
+		"launch":  `<configuration><option name="retry-count" value="5" /></configuration>`,
+		"retired": `<configuration />`,
+	}, map[string]string{
+		"csuite_com.example.a": strings.Replace(module, "%s", "com.example.a", 1),
+		"csuite_com.example.b": strings.Replace(module, "%s", "com.example.b", 1),
+		"csuite_com.example.c": strings.Replace(module, "%s", "com.example.c", 1),
+		"csuite_com.example.d": `<configuration />`,
+		"helper":               `<configuration />`,
+	})
+	new := newSuite(map[string]string{
+		"launch": `<configuration><option name="retry-count" value="3" /></configuration>`,
+		"crawl":  `<configuration description="C-Suite crawler" />`,
+	}, map[string]string{
+		"csuite_com.example.a": strings.Replace(changedModule, "%s", "com.example.a", 1),
+		"csuite_com.example.b": strings.Replace(changedModule, "%s", "com.example.b", 1),
+		"csuite_com.example.c": strings.Replace(module, "%s", "com.example.c", 1),
+		"csuite_com.example.d": `<configuration description="d" />`,
+		"csuite_com.example.e": `<configuration />`,
+	})
+	var b strings.Builder
+
+	err := build(old, new, "P1", "P2").write(&b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `C-Suite changes from P1 to P2
+
+New plans (1):
+  * crawl: C-Suite crawler
+
+Removed plans (1):
+  * retired
+
+Changed plans (1):
+  * launch
+      option retry-count: 5 -> 3
+
+Added apps (1):
+  * com.example.e
+
+Removed modules (1):
+  * helper
+
+Changed modules (3):
+  * 2 modules: csuite_com.example.a, csuite_com.example.b
+      option retry added: 1
+  * csuite_com.example.d
+      description added: d
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWrite_noChanges(t *testing.T) {
+	s := newSuite(map[string]string{"launch": `<configuration />`}, nil)
+	var b strings.Builder
+
+	if err := build(s, s, "P1", "P2").write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "C-Suite changes from P1 to P2\n\nNo plan, app or module changes.\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
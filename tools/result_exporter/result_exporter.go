@@ -0,0 +1,278 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// result_exporter exports the results of a C-Suite run to one or more
+// backends, so that labs with different reporting stacks can share one
+// exporter.
+//
+// Usage:
+//
+//	result_exporter -to <backend>[:<destination>]... <test_result.xml or results dir>
+//
+// The backends are:
+//
+//	stdout            a table of the results, one app per line
+//	json:<file>       the run and its rows as one JSON document
+//	http:<url>        the JSON document, POSTed to the URL; the value of the
+//	                  RESULT_EXPORTER_TOKEN environment variable, if set, is
+//	                  sent as a bearer token
+//	bigquery:<file>   newline-delimited JSON with one flat row per app, for
+//	                  bq load --source_format=NEWLINE_DELIMITED_JSON
+//
+// New backends are added to the backends map.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+const tokenEnv = "RESULT_EXPORTER_TOKEN"
+
+// run is what is exported: a run and the rows of its apps.
+type run struct {
+	Suite            string       `json:"suite"`
+	Plan             string       `json:"plan"`
+	Start            time.Time    `json:"start"`
+	End              time.Time    `json:"end"`
+	BuildFingerprint string       `json:"build_fingerprint"`
+	DeviceModel      string       `json:"device_model"`
+	Rows             []result.Row `json:"rows"`
+}
+
+func newRun(r *result.Result) *run {
+	rows := r.Rows()
+	if rows == nil {
+		rows = []result.Row{}
+	}
+	return &run{
+		Suite:            r.SuiteName,
+		Plan:             r.SuitePlan,
+		Start:            time.UnixMilli(r.Start).UTC(),
+		End:              time.UnixMilli(r.End).UTC(),
+		BuildFingerprint: r.Build.Fingerprint,
+		DeviceModel:      r.Build.Model,
+		Rows:             rows,
+	}
+}
+
+// backend exports runs to one destination.
+type backend interface {
+	export(r *run) error
+}
+
+// backends creates the backend of each name for a destination, which is
+// empty when none was given.
+var backends = map[string]func(dest string) (backend, error){
+	"stdout": func(dest string) (backend, error) {
+		if dest != "" {
+			return nil, fmt.Errorf("stdout takes no destination")
+		}
+		return &tableBackend{w: os.Stdout}, nil
+	},
+	"json": func(dest string) (backend, error) {
+		return &fileBackend{path: dest, write: writeJSON}, requireDest(dest)
+	},
+	"bigquery": func(dest string) (backend, error) {
+		return &fileBackend{path: dest, write: writeBigQueryRows}, requireDest(dest)
+	},
+	"http": func(dest string) (backend, error) {
+		return &httpBackend{url: dest, client: http.DefaultClient, token: os.Getenv(tokenEnv)}, requireDest(dest)
+	},
+}
+
+func requireDest(dest string) error {
+	if dest == "" {
+		return fmt.Errorf("a destination is required")
+	}
+	return nil
+}
+
+// parseTarget creates the backend for a <backend>[:<destination>] flag.
+func parseTarget(target string) (backend, error) {
+	name, dest := target, ""
+	if i := strings.Index(target, ":"); i >= 0 {
+		name, dest = target[:i], target[i+1:]
+	}
+	newBackend, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	b, err := newBackend(dest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return b, nil
+}
+
+type tableBackend struct {
+	w io.Writer
+}
+
+func (b *tableBackend) export(r *run) error {
+	tw := tabwriter.NewWriter(b.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "PACKAGE\tABI\tOUTCOME\tERRORS\n")
+	for _, row := range r.Rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.Package, row.ABI, row.Outcome, strings.Join(row.ErrorTypes, ","))
+	}
+	return tw.Flush()
+}
+
+type fileBackend struct {
+	path  string
+	write func(w io.Writer, r *run) error
+}
+
+func (b *fileBackend) export(r *run) error {
+	f, err := os.Create(b.path)
+	if err != nil {
+		return err
+	}
+	if err := b.write(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeJSON(w io.Writer, r *run) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+// bigQueryRow is a row of the BigQuery export, with the run fields repeated
+// on every row.
+type bigQueryRow struct {
+	Plan             string   `json:"plan"`
+	StartTime        string   `json:"start_time"`
+	BuildFingerprint string   `json:"build_fingerprint"`
+	DeviceModel      string   `json:"device_model"`
+	Package          string   `json:"package"`
+	Module           string   `json:"module"`
+	ABI              string   `json:"abi"`
+	Outcome          string   `json:"outcome"`
+	Status           string   `json:"status"`
+	ErrorTypes       []string `json:"error_types"`
+	Message          string   `json:"message"`
+}
+
+func writeBigQueryRows(w io.Writer, r *run) error {
+	e := json.NewEncoder(w)
+	for _, row := range r.Rows {
+		errorTypes := row.ErrorTypes
+		if errorTypes == nil {
+			errorTypes = []string{}
+		}
+		if err := e.Encode(bigQueryRow{
+			Plan:             r.Plan,
+			StartTime:        r.Start.Format(time.RFC3339),
+			BuildFingerprint: r.BuildFingerprint,
+			DeviceModel:      r.DeviceModel,
+			Package:          row.Package,
+			Module:           row.Module,
+			ABI:              row.ABI,
+			Outcome:          row.Outcome,
+			Status:           row.Status,
+			ErrorTypes:       errorTypes,
+			Message:          row.Message,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type httpBackend struct {
+	url    string
+	client *http.Client
+	token  string
+}
+
+func (b *httpBackend) export(r *run) error {
+	var body bytes.Buffer
+	if err := writeJSON(&body, r); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, b.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s failed: %s", b.url, resp.Status)
+	}
+	return nil
+}
+
+func main() {
+	var targets flagutil.StringList
+	flag.Var(&targets, "to", "backend to export to, as <backend>[:<destination>]; may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s -to <backend>[:<destination>]... <test_result.xml or results dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if len(targets) == 0 || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var exporters []backend
+	for _, t := range targets {
+		b, err := parseTarget(t)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		exporters = append(exporters, b)
+	}
+	res, err := result.ParseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	r := newRun(res)
+	failed := false
+	for i, b := range exporters {
+		if err := b.export(r); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", targets[i], err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+var testRun = &run{
+	Suite:            "CSUITE",
+	Plan:             "launch",
+	Start:            time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC),
+	BuildFingerprint: "google/device/device:11",
+	DeviceModel:      "Pixel",
+	Rows: []result.Row{
+		{Package: "com.example.a", Module: "csuite_com.example.a", ABI: "arm64-v8a", Outcome: result.Pass},
+		{Package: "com.example.b", Module: "csuite_com.example.b", ABI: "arm64-v8a", Outcome: result.Crash,
+			ErrorTypes: []string{"crash"}},
+	},
+}
+
+func TestNewRun(t *testing.T) {
+	r := newRun(&result.Result{SuitePlan: "launch", Start: 1600000000000, Build: result.Build{Model: "Pixel"}})
+
+	if r.Plan != "launch" || r.DeviceModel != "Pixel" || !r.Start.Equal(time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)) {
+		t.Errorf("got %+v", r)
+	}
+	if r.Rows == nil {
+		t.Error("got nil rows, want empty")
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for _, target := range []string{"stdout", "json:out.json", "bigquery:rows.json", "http:https://example.com/results"} {
+		if _, err := parseTarget(target); err != nil {
+			t.Errorf("parseTarget(%q) failed: %v", target, err)
+		}
+	}
+	if b, _ := parseTarget("http:https://example.com/results"); b.(*httpBackend).url != "https://example.com/results" {
+		t.Errorf("got %+v", b)
+	}
+	for _, target := range []string{"", "ftp:x", "json", "stdout:x", "http:"} {
+		if _, err := parseTarget(target); err == nil {
+			t.Errorf("parseTarget(%q) succeeded, want error", target)
+		}
+	}
+}
+
+func TestTableBackend(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := (&tableBackend{w: &b}).export(testRun); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "PACKAGE        ABI        OUTCOME  ERRORS\n" +
+		"com.example.a  arm64-v8a  pass     \n" +
+		"com.example.b  arm64-v8a  crash    crash\n"
+	if b.String() != want {
+		t.Errorf("got\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestFileBackend_json(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.json")
+
+	if err := (&fileBackend{path: path, write: writeJSON}).export(testRun); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got run
+	if err := json.Unmarshal(data, &got); err != nil || got.Plan != "launch" || len(got.Rows) != 2 {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
+
+func TestWriteBigQueryRows_writesOneFlatRowPerLine(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := writeBigQueryRows(&b, testRun); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	want := `{"plan":"launch","start_time":"2020-09-13T12:26:40Z","build_fingerprint":"google/device/device:11",` +
+		`"device_model":"Pixel","package":"com.example.a","module":"csuite_com.example.a","abi":"arm64-v8a",` +
+		`"outcome":"pass","status":"","error_types":[],"message":""}`
+	if lines[0] != want {
+		t.Errorf("got %s\nwant %s", lines[0], want)
+	}
+}
+
+func TestHTTPBackend_postsJSONWithToken(t *testing.T) {
+	var gotAuth, gotType string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotType = r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	err := (&httpBackend{url: server.URL, client: server.Client(), token: "secret"}).export(testRun)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotAuth != "Bearer secret" || gotType != "application/json" || !bytes.Contains(gotBody, []byte(`"plan": "launch"`)) {
+		t.Errorf("got %q, %q, %s", gotAuth, gotType, gotBody)
+	}
+}
+
+func TestHTTPBackend_errorStatus_returnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := (&httpBackend{url: server.URL, client: server.Client()}).export(testRun); err == nil {
+		t.Error("export() succeeded, want error")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// result_parser converts the results of a C-Suite run into one row per app
+// and ABI, as JSON or CSV, so that runs can be analyzed without the full
+// reporting stack.
+//
+// Usage:
+//
+//	result_parser [-format json|csv] [-o <file>] <test_result.xml or results dir>
+//
+// Each row has the package, module, ABI and outcome of an app: pass, crash
+// (the app failed to launch, crashed or stopped responding), fail (e.g. the
+// app could not be installed) or skip. Failed rows also have the harness
+// status, the types of app errors detected and the failure message.
+//
+// Only the XML result format is read; the protobuf results written by the
+// proto reporters are not supported.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+func writeRows(w io.Writer, format string, rows []result.Row) error {
+	switch format {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		if rows == nil {
+			rows = []result.Row{}
+		}
+		return e.Encode(rows)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"package", "module", "abi", "outcome", "status", "error_types", "message"})
+		for _, r := range rows {
+			cw.Write([]string{r.Package, r.Module, r.ABI, r.Outcome, r.Status, strings.Join(r.ErrorTypes, ";"), r.Message})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	format := flag.String("format", "json", "output format: json or csv")
+	out := flag.String("o", "", "output file; defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-format json|csv] [-o <file>] <test_result.xml or results dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "json" && *format != "csv") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r, err := result.ParseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var f *os.File
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		w = f
+	}
+	err = writeRows(w, *format, r.Rows())
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
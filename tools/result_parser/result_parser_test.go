@@ -0,0 +1,75 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+)
+
+var rows = []result.Row{
+	{Package: "com.example.a", Module: "csuite_com.example.a", ABI: "arm64-v8a", Outcome: result.Pass},
+	{Package: "com.example.b", Module: "csuite_com.example.b", ABI: "arm64-v8a", Outcome: result.Crash,
+		Status: result.StatusFailure, ErrorTypes: []string{"crash", "ANR"}, Message: "Error, \"quoted\""},
+}
+
+func TestWriteRows_csv(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := writeRows(&b, "csv", rows); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "package,module,abi,outcome,status,error_types,message\n" +
+		"com.example.a,csuite_com.example.a,arm64-v8a,pass,,,\n" +
+		"com.example.b,csuite_com.example.b,arm64-v8a,crash,failure,crash;ANR,\"Error, \"\"quoted\"\"\"\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteRows_json_roundTrips(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := writeRows(&b, "json", rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []result.Row
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("got %+v, want %+v", got, rows)
+	}
+}
+
+func TestWriteRows_jsonNoRows_writesEmptyArray(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := writeRows(&b, "json", nil); err != nil || b.String() != "[]\n" {
+		t.Errorf("got %q, %v", b.String(), err)
+	}
+}
+
+func TestWriteRows_unknownFormat_returnsError(t *testing.T) {
+	if err := writeRows(&bytes.Buffer{}, "xml", rows); err == nil {
+		t.Error("writeRows() succeeded, want error")
+	}
+}
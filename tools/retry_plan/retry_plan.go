@@ -0,0 +1,136 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// retry_plan reads the results of a completed run and prints the
+// csuite-tradefed command that reruns only the apps that failed, so that
+// retries do not repeat hours of passing modules.
+//
+// Usage:
+//
+//	retry_plan [-plan <name>] [-retry crash,fail] [-filters <file>] <test_result.xml or results dir>
+//
+// The plan defaults to the plan of the run. Each failed app is selected by
+// an include filter on its module and ABI. With -filters, the include
+// filters are also written as a Tradefed config, for plans that <include>
+// them instead of passing them on the command line.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	generator           = "test/app_compat/csuite/tools/retry_plan"
+	includeFilterOption = "compatibility:include-filter"
+	tradefed            = "csuite-tradefed"
+)
+
+// includeFilters returns the sorted, deduplicated "<abi> <module>" filters
+// selecting the rows whose outcome is in outcomes.
+func includeFilters(rows []result.Row, outcomes []string) []string {
+	retry := make(map[string]bool)
+	for _, o := range outcomes {
+		retry[o] = true
+	}
+	seen := make(map[string]bool)
+	var filters []string
+	for _, r := range rows {
+		if !retry[r.Outcome] || r.Module == "" {
+			continue
+		}
+		filter := strings.TrimSpace(r.ABI + " " + r.Module)
+		if !seen[filter] {
+			seen[filter] = true
+			filters = append(filters, filter)
+		}
+	}
+	sort.Strings(filters)
+	return filters
+}
+
+// commandLine returns the csuite-tradefed command running plan with filters,
+// quoted for a POSIX shell.
+func commandLine(plan string, filters []string) string {
+	args := []string{tradefed, "run", "commandAndExit", shellQuote(plan)}
+	for _, f := range filters {
+		args = append(args, "--include-filter", shellQuote(f))
+	}
+	return strings.Join(args, " ")
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:/=", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func filtersConfig(plan string, filters []string) *tfconfig.Configuration {
+	c := &tfconfig.Configuration{Description: fmt.Sprintf("Retry of the failed apps of a %s run", plan)}
+	for _, f := range filters {
+		c.Options = append(c.Options, tfconfig.Option{Name: includeFilterOption, Value: f})
+	}
+	return c
+}
+
+func main() {
+	plan := flag.String("plan", "", "plan to rerun; defaults to the plan of the run")
+	retry := flag.String("retry", result.Crash+","+result.Fail, "comma-separated outcomes to retry")
+	filters := flag.String("filters", "", "also write the include filters to this file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-plan <name>] [-retry crash,fail] [-filters <file>] <test_result.xml or results dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r, err := result.ParseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *plan == "" {
+		*plan = r.SuitePlan
+	}
+	if *plan == "" {
+		fmt.Fprintln(os.Stderr, "the result does not name its plan; set -plan")
+		os.Exit(2)
+	}
+
+	included := includeFilters(r.Rows(), strings.Split(*retry, ","))
+	if len(included) == 0 {
+		fmt.Fprintln(os.Stderr, "nothing to retry")
+		return
+	}
+	if *filters != "" {
+		if err := os.WriteFile(*filters, tfconfig.MarshalGenerated(filtersConfig(*plan, included), generator), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	fmt.Println(commandLine(*plan, included))
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func TestIncludeFilters_selectsRetriedOutcomes(t *testing.T) {
+	rows := []result.Row{
+		{Module: "csuite_com.example.pass", ABI: "arm64-v8a", Outcome: result.Pass},
+		{Module: "csuite_com.example.crash", ABI: "arm64-v8a", Outcome: result.Crash},
+		{Module: "csuite_com.example.crash", ABI: "arm64-v8a", Outcome: result.Crash},
+		{Module: "csuite_com.example.crash", ABI: "armeabi-v7a", Outcome: result.Pass},
+		{Module: "csuite_com.example.fail", Outcome: result.Fail},
+		{Module: "csuite_com.example.skip", ABI: "arm64-v8a", Outcome: result.Skip},
+	}
+
+	got := includeFilters(rows, []string{result.Crash, result.Fail})
+
+	if want := []string{"arm64-v8a csuite_com.example.crash", "csuite_com.example.fail"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCommandLine_quotesFilters(t *testing.T) {
+	got := commandLine("launch", []string{"arm64-v8a csuite_com.example.a", "csuite_it's"})
+
+	want := `csuite-tradefed run commandAndExit launch --include-filter 'arm64-v8a csuite_com.example.a' --include-filter 'csuite_it'\''s'`
+	if got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestFiltersConfig(t *testing.T) {
+	c := filtersConfig("launch", []string{"arm64-v8a csuite_com.example.a"})
+
+	if got := tfconfig.OptionValues(c.Options, includeFilterOption); !reflect.DeepEqual(got, []string{"arm64-v8a csuite_com.example.a"}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// shard_planner splits an app list into shards of about equal runtime,
+// using the module runtimes of previous runs, and writes the include
+// filters of each shard as a Tradefed config.
+//
+// Usage:
+//
+//	shard_planner -shards <n> [-history <test_result.xml or results dir>]... [-abi <abi>] -o <dir> <package list>
+//
+// The runtime of an app is the average over the history runs of the total
+// runtime of its module across ABIs. Apps without history are assumed to
+// take the median runtime of the others. Apps are assigned longest first to
+// the shard with the least total runtime so far. There must be at least as
+// many apps as shards, since a shard without include filters would run
+// every app. Shard i of n is written to shard-<i>.xml in the output
+// directory, with one compatibility:include-filter option per app, for plans
+// to <include> like the filters written by retry_plan. A summary of the
+// shards is printed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	generator           = "test/app_compat/csuite/tools/shard_planner"
+	includeFilterOption = "compatibility:include-filter"
+)
+
+type shard struct {
+	modules []string
+	// runtime is the estimated runtime in milliseconds.
+	runtime int64
+}
+
+// runtimes returns the average runtime in milliseconds of each module over
+// the runs it appears in, summing the runtimes of its ABIs within a run.
+func runtimes(runs []*result.Result) map[string]int64 {
+	totals := make(map[string]int64)
+	counts := make(map[string]int64)
+	for _, r := range runs {
+		run := make(map[string]int64)
+		for _, m := range r.Modules {
+			run[m.Name] += m.Runtime
+		}
+		for name, t := range run {
+			totals[name] += t
+			counts[name]++
+		}
+	}
+	avg := make(map[string]int64)
+	for name, t := range totals {
+		avg[name] = t / counts[name]
+	}
+	return avg
+}
+
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	s := append([]int64(nil), values...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[len(s)/2]
+}
+
+// plan assigns modules to n shards, longest first, each to the shard with
+// the least runtime so far. It fails if there are fewer modules than shards,
+// since a shard without include filters would run every module.
+func plan(modules []string, history map[string]int64, n int) ([]shard, error) {
+	if n > len(modules) {
+		return nil, fmt.Errorf("%d shards for %d apps; every shard needs at least one app", n, len(modules))
+	}
+	estimates := make(map[string]int64)
+	var known []int64
+	for _, m := range modules {
+		if t, ok := history[m]; ok {
+			known = append(known, t)
+		}
+	}
+	fallback := median(known)
+	for _, m := range modules {
+		if t, ok := history[m]; ok {
+			estimates[m] = t
+		} else {
+			estimates[m] = fallback
+		}
+	}
+
+	sorted := append([]string(nil), modules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := estimates[sorted[i]], estimates[sorted[j]]
+		if a != b {
+			return a > b
+		}
+		return sorted[i] < sorted[j]
+	})
+	shards := make([]shard, n)
+	for _, m := range sorted {
+		best := 0
+		for i := range shards {
+			if shards[i].runtime < shards[best].runtime ||
+				(shards[i].runtime == shards[best].runtime && len(shards[i].modules) < len(shards[best].modules)) {
+				best = i
+			}
+		}
+		shards[best].modules = append(shards[best].modules, m)
+		shards[best].runtime += estimates[m]
+	}
+	for i := range shards {
+		sort.Strings(shards[i].modules)
+	}
+	return shards, nil
+}
+
+func shardConfig(s shard, index, n int, abi string) *tfconfig.Configuration {
+	c := &tfconfig.Configuration{Description: fmt.Sprintf("Shard %d of %d of the apps", index, n)}
+	for _, m := range s.modules {
+		c.Options = append(c.Options, tfconfig.Option{Name: includeFilterOption, Value: strings.TrimSpace(abi + " " + m)})
+	}
+	return c
+}
+
+func main() {
+	var history flagutil.StringList
+	n := flag.Int("shards", 0, "number of shards")
+	flag.Var(&history, "history", "test_result.xml or results dir of a previous run; may be repeated")
+	abi := flag.String("abi", "", "ABI to prefix the include filters with")
+	out := flag.String("o", "", "directory to write the shard configs to")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s -shards <n> [-history <test_result.xml or results dir>]... [-abi <abi>] -o <dir> <package list>\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *n < 1 || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	packages, err := packagelist.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var modules []string
+	for _, p := range packagelist.Dedup(packages) {
+		modules = append(modules, packagelist.ModuleName(p))
+	}
+	var runs []*result.Result
+	for _, h := range history {
+		r, err := result.ParseFile(h)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		runs = append(runs, r)
+	}
+
+	shards, err := plan(modules, runtimes(runs), *n)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for i, s := range shards {
+		path := filepath.Join(*out, fmt.Sprintf("shard-%d.xml", i+1))
+		data := tfconfig.MarshalGenerated(shardConfig(s, i+1, *n, *abi), generator)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("%s: %d apps, about %v\n", path, len(s.modules), time.Duration(s.runtime)*time.Millisecond)
+	}
+}
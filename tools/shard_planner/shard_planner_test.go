@@ -0,0 +1,93 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/result"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func TestRuntimes(t *testing.T) {
+	runs := []*result.Result{
+		{Modules: []result.Module{
+			{Name: "csuite_a", ABI: "arm64-v8a", Runtime: 100},
+			{Name: "csuite_a", ABI: "armeabi-v7a", Runtime: 50},
+			{Name: "csuite_b", ABI: "arm64-v8a", Runtime: 10},
+		}},
+		{Modules: []result.Module{
+			{Name: "csuite_a", ABI: "arm64-v8a", Runtime: 50},
+		}},
+	}
+
+	got := runtimes(runs)
+
+	if want := map[string]int64{"csuite_a": 100, "csuite_b": 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	history := map[string]int64{"a": 70, "b": 50, "c": 40, "d": 30, "e": 10}
+
+	got, err := plan([]string{"a", "b", "c", "d", "e", "f"}, history, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// f has no history and is estimated at the median, 40.
+	want := []shard{
+		{modules: []string{"a", "e", "f"}, runtime: 120},
+		{modules: []string{"b", "c", "d"}, runtime: 120},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPlan_moreShardsThanApps_returnsError(t *testing.T) {
+	if _, err := plan([]string{"a"}, nil, 3); err == nil {
+		t.Error("got no error for more shards than apps")
+	}
+}
+
+func TestPlan_asManyShardsAsApps(t *testing.T) {
+	got, err := plan([]string{"a", "b"}, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []shard{{modules: []string{"a"}}, {modules: []string{"b"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestShardConfig(t *testing.T) {
+	got := shardConfig(shard{modules: []string{"csuite_a", "csuite_b"}}, 1, 2, "arm64-v8a")
+
+	want := &tfconfig.Configuration{
+		Description: "Shard 1 of 2 of the apps",
+		Options: []tfconfig.Option{
+			{Name: includeFilterOption, Value: "arm64-v8a csuite_a"},
+			{Name: includeFilterOption, Value: "arm64-v8a csuite_b"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
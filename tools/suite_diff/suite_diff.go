@@ -0,0 +1,77 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// suite_diff compares two builds of C-Suite and reports the plans and
+// module configs that were added, removed or changed, down to the changed
+// includes, template includes and options, for release review.
+//
+// Usage:
+//
+//	suite_diff [-json] <old suite zip or dir> <new suite zip or dir>
+//
+// The text output lists plans and modules prefixed by + (added), - (removed)
+// or ~ (changed), with one line per change. The -json output is a
+// machine-readable form of the same report. Like diff, suite_diff exits with
+// status 1 when the suites differ.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/suitediff"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] <old suite zip or dir> <new suite zip or dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var suites [2]*suite.Suite
+	for i := range suites {
+		s, err := suite.Open(flag.Arg(i))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		suites[i] = s
+	}
+
+	diff := suitediff.Compare(suites[0], suites[1])
+	var err error
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(diff)
+	} else {
+		err = diff.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// suite_inspector prints the structure of a built C-Suite: its plans and
+// what they include, the tools with their sizes and checksums, and a
+// summary of the test cases. It flags files under testcases that no config
+// refers to, which are usually left over from removed modules.
+//
+// Usage:
+//
+//	suite_inspector [-json] [-files] <suite zip or dir>
+//
+// With -files, every file is listed with its size and SHA-256 checksum. The
+// JSON output always lists every file.
+//
+// A test file counts as referenced when it is a module config, when it is in
+// the directory of a module, or when its path or name is the value of an
+// option in any plan or module config.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const toolsDir = "tools"
+
+type fileInfo struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type planInfo struct {
+	Name             string   `json:"name"`
+	Path             string   `json:"path"`
+	Description      string   `json:"description,omitempty"`
+	Includes         []string `json:"includes,omitempty"`
+	TemplateIncludes []string `json:"template_includes,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+type report struct {
+	Plans     []planInfo `json:"plans"`
+	Tools     []fileInfo `json:"tools"`
+	Modules   int        `json:"modules"`
+	TotalSize int64      `json:"total_size"`
+	Files     []fileInfo `json:"files"`
+	Orphans   []string   `json:"orphans"`
+}
+
+// listFiles returns every file in fsys with its size and checksum, sorted by
+// path.
+func listFiles(fsys fs.FS) ([]fileInfo, error) {
+	var files []fileInfo
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		n, err := io.Copy(h, f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		files = append(files, fileInfo{Path: p, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+func describePlans(s *suite.Suite) []planInfo {
+	var plans []planInfo
+	for _, c := range s.Plans() {
+		info := planInfo{Name: c.Name, Path: c.Path}
+		parsed, err := tfconfig.Parse(c.Data)
+		if err != nil {
+			info.Error = err.Error()
+			plans = append(plans, info)
+			continue
+		}
+		info.Description = parsed.Description
+		for _, i := range parsed.Includes {
+			info.Includes = append(info.Includes, i.Name)
+		}
+		for _, i := range parsed.TemplateIncludes {
+			info.TemplateIncludes = append(info.TemplateIncludes, i.Name+"="+i.Default)
+		}
+		plans = append(plans, info)
+	}
+	return plans
+}
+
+// orphans returns the test files that no config refers to.
+func orphans(s *suite.Suite) []string {
+	referenced := make(map[string]bool)
+	var configs []*suite.Config
+	configs = append(configs, s.Plans()...)
+	configs = append(configs, s.SortedModules()...)
+	for _, c := range configs {
+		parsed, err := tfconfig.Parse(c.Data)
+		if err != nil {
+			continue
+		}
+		for _, o := range parsed.AllOptions() {
+			referenced[o.Value] = true
+		}
+	}
+
+	var orphans []string
+	for p := range s.TestFiles {
+		dir := strings.SplitN(p, "/", 2)[0]
+		if _, ok := s.Modules[dir]; (ok && dir != p) || strings.HasSuffix(p, ".config") ||
+			referenced[p] || referenced[path.Base(p)] {
+			continue
+		}
+		orphans = append(orphans, p)
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+func isTool(p string) bool {
+	elems := strings.Split(p, "/")
+	for _, e := range elems[:len(elems)-1] {
+		if e == toolsDir {
+			return true
+		}
+	}
+	return false
+}
+
+func inspect(s *suite.Suite, files []fileInfo) *report {
+	r := &report{
+		Plans:   describePlans(s),
+		Modules: len(s.Modules),
+		Files:   files,
+		Orphans: orphans(s),
+	}
+	for _, f := range files {
+		r.TotalSize += f.Size
+		if isTool(f.Path) {
+			r.Tools = append(r.Tools, f)
+		}
+	}
+	return r
+}
+
+func (r *report) writeText(w io.Writer, listAll bool) {
+	fmt.Fprintln(w, "Plans:")
+	for _, p := range r.Plans {
+		fmt.Fprintf(w, "  %s (%s)\n", p.Name, p.Path)
+		if p.Error != "" {
+			fmt.Fprintf(w, "      error: %s\n", p.Error)
+		}
+		if len(p.Includes) > 0 {
+			fmt.Fprintf(w, "      includes: %s\n", strings.Join(p.Includes, ", "))
+		}
+		if len(p.TemplateIncludes) > 0 {
+			fmt.Fprintf(w, "      template-includes: %s\n", strings.Join(p.TemplateIncludes, ", "))
+		}
+	}
+	fmt.Fprintln(w, "Tools:")
+	for _, f := range r.Tools {
+		fmt.Fprintf(w, "  %s %d %s\n", f.Path, f.Size, f.SHA256)
+	}
+	fmt.Fprintf(w, "Modules: %d\n", r.Modules)
+	fmt.Fprintf(w, "Files: %d, %d bytes\n", len(r.Files), r.TotalSize)
+	if listAll {
+		for _, f := range r.Files {
+			fmt.Fprintf(w, "  %s %d %s\n", f.Path, f.Size, f.SHA256)
+		}
+	}
+	if len(r.Orphans) > 0 {
+		fmt.Fprintln(w, "Test files not referenced by any config:")
+		for _, p := range r.Orphans {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	listAll := flag.Bool("files", false, "list every file with its size and checksum")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] [-files] <suite zip or dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := suite.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fsys, closer, err := suite.OpenFS(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer closer.Close()
+	files, err := listFiles(fsys)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	r := inspect(s, files)
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		e.Encode(r)
+		return
+	}
+	r.writeText(os.Stdout, *listAll)
+}
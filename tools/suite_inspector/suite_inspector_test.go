@@ -0,0 +1,106 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"android/test/app_compat/csuite/tools/internal/suite"
+)
+
+const moduleConfig = `<configuration>
+  <target_preparer class="com.android.tradefed.targetprep.suite.SuiteApkInstaller">
+    <option name="test-file-name" value="csuite-launch-instrumentation.apk" />
+  </target_preparer>
+</configuration>`
+
+func TestListFiles_sizesAndChecksums(t *testing.T) {
+	fsys := fstest.MapFS{
+		"android-csuite/tools/csuite-tradefed.jar": {Data: []byte("jar")},
+		"android-csuite/testcases/empty":           {Data: nil},
+	}
+
+	got, err := listFiles(fsys)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	jarSum := sha256.Sum256([]byte("jar"))
+	want := []fileInfo{
+		{Path: "android-csuite/testcases/empty", Size: 0,
+			SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{Path: "android-csuite/tools/csuite-tradefed.jar", Size: 3, SHA256: hex.EncodeToString(jarSum[:])},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestOrphans_flagsUnreferencedTestFiles(t *testing.T) {
+	s := suite.New()
+	s.Configs["launch"] = &suite.Config{Name: "launch", Plan: true, Data: []byte("<configuration />")}
+	s.Modules["csuite_com.example.a"] = &suite.Config{Name: "csuite_com.example.a", Data: []byte(moduleConfig)}
+	for _, p := range []string{
+		"csuite_com.example.a.config",
+		"csuite_com.example.a/csuite_com.example.a.config",
+		"csuite_com.example.a/data.bin",
+		"csuite-launch-instrumentation.apk",
+		"old-instrumentation.apk",
+		"csuite_com.example.removed/data.bin",
+	} {
+		s.TestFiles[p] = true
+	}
+
+	got := orphans(s)
+
+	if want := []string{"csuite_com.example.removed/data.bin", "old-instrumentation.apk"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInspect_describesPlansAndTools(t *testing.T) {
+	s := suite.New()
+	s.Configs["launch"] = &suite.Config{Name: "launch", Path: "launch.xml", Plan: true, Data: []byte(
+		`<configuration description="Launch"><include name="csuite-base" />` +
+			`<template-include name="reporters" default="basic-reporters" /></configuration>`)}
+	s.Configs["broken"] = &suite.Config{Name: "broken", Path: "broken.xml", Plan: true, Data: []byte("<configuration>")}
+	files := []fileInfo{
+		{Path: "android-csuite/testcases/a.apk", Size: 10},
+		{Path: "android-csuite/tools/csuite-tradefed.jar", Size: 5},
+	}
+
+	r := inspect(s, files)
+
+	if r.TotalSize != 15 || len(r.Tools) != 1 || r.Tools[0].Path != "android-csuite/tools/csuite-tradefed.jar" {
+		t.Errorf("got %+v", r)
+	}
+	var b bytes.Buffer
+	r.writeText(&b, false)
+	for _, want := range []string{
+		"  launch (launch.xml)\n      includes: csuite-base\n      template-includes: reporters=basic-reporters\n",
+		"  broken (broken.xml)\n      error: ",
+		"Files: 2, 15 bytes\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
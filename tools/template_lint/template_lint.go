@@ -0,0 +1,404 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// csuite_template_lint checks C-Suite module configs and plans before they
+// are sent to the build, and can fix the common issues in place.
+//
+// Usage:
+//
+//	csuite_template_lint [-fix] <file or dir>...
+//
+// Directories are searched for AndroidTest.xml files and for .xml files in
+// directories named config. Findings are printed as "path:line: message
+// [rule]"; the ones marked fixable are rewritten by -fix without touching
+// the rest of the file.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	appLaunchTestClass = "com.android.compatibility.testtype.AppLaunchTest"
+	csuiteClassPrefix  = "com.android.compatibility."
+	packageNameOption  = "package-name"
+	metadataOption     = "config-descriptor:metadata"
+	planMetadataKey    = "plan"
+	testElement        = "test"
+	optionElement      = "option"
+)
+
+// deprecatedOption is an option that is still accepted by the harness but
+// should no longer be set in configs.
+type deprecatedOption struct {
+	class string
+	name  string
+}
+
+var deprecatedOptions = []deprecatedOption{
+	{class: appLaunchTestClass, name: "retry-count"},
+}
+
+var entityRef = regexp.MustCompile(`^&(#[0-9]+|#x[0-9a-fA-F]+|[A-Za-z_][A-Za-z0-9._-]*);`)
+
+type finding struct {
+	path    string
+	line    int
+	rule    string
+	message string
+	fixable bool
+}
+
+func (f finding) String() string {
+	s := fmt.Sprintf("%s:%d: %s [%s]", f.path, f.line, f.message, f.rule)
+	if f.fixable {
+		s += " (fixable)"
+	}
+	return s
+}
+
+// lint returns the findings for the config at path, in line order.
+func lint(path string, data []byte) []finding {
+	var findings []finding
+	add := func(line int, rule, message string, fixable bool) {
+		findings = append(findings, finding{path, line, rule, message, fixable})
+	}
+
+	for _, offset := range unescapedAmpersands(data) {
+		add(lineAt(data, offset), "escaping", "unescaped '&'", true)
+	}
+	data = escapeAmpersands(data)
+
+	c, err := tfconfig.Parse(data)
+	if err != nil {
+		add(syntaxErrorLine(err), "well-formed", err.Error(), false)
+		return findings
+	}
+
+	for _, o := range findDeprecatedOptions(data) {
+		add(lineAt(data, o.start), "deprecated-option",
+			fmt.Sprintf("option %q of %s is deprecated", o.name, o.class), true)
+	}
+	if len(c.ObjectsOfClass(appLaunchTestClass)) > 0 &&
+		len(tfconfig.OptionValues(c.AllOptions(), packageNameOption)) == 0 {
+		add(1, "package-name", fmt.Sprintf("%s needs a %q option", appLaunchTestClass, packageNameOption), false)
+	}
+	if hasCSuiteTest(c) && !hasPlanMetadata(c) {
+		add(1, "plan-metadata", fmt.Sprintf(
+			"module configs need a %q option with key %q so that plans can select them",
+			metadataOption, planMetadataKey), false)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].line < findings[j].line })
+	return findings
+}
+
+// fix returns data with all fixable findings fixed.
+func fix(data []byte) ([]byte, error) {
+	data = escapeAmpersands(data)
+	if _, err := tfconfig.Parse(data); err != nil {
+		return nil, err
+	}
+	options := findDeprecatedOptions(data)
+	for i := len(options) - 1; i >= 0; i-- {
+		start, end := expandToLine(data, options[i].start, options[i].end)
+		data = append(data[:start:start], data[end:]...)
+	}
+	return data, nil
+}
+
+// hasCSuiteTest reports whether c runs one of the C-Suite test types, as
+// opposed to e.g. the host tests of the harness itself.
+func hasCSuiteTest(c *tfconfig.Configuration) bool {
+	for _, o := range c.Objects {
+		if o.Type() == testElement && strings.HasPrefix(o.Class, csuiteClassPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPlanMetadata(c *tfconfig.Configuration) bool {
+	for _, o := range c.Options {
+		if o.Name == metadataOption && o.Key == planMetadataKey {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapedAmpersands returns the offsets of '&' characters that do not
+// start an entity or character reference, ignoring comments, CDATA sections
+// and processing instructions.
+func unescapedAmpersands(data []byte) []int {
+	var offsets []int
+	for i := 0; i < len(data); i++ {
+		if skip := skipLiteral(data[i:]); skip > 0 {
+			i += skip - 1
+			continue
+		}
+		if data[i] == '&' && !entityRef.Match(data[i:]) {
+			offsets = append(offsets, i)
+		}
+	}
+	return offsets
+}
+
+// skipLiteral returns the length of the comment, CDATA section or processing
+// instruction that data starts with, or 0 if it starts with none of them.
+func skipLiteral(data []byte) int {
+	for _, delims := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if !bytes.HasPrefix(data, []byte(delims[0])) {
+			continue
+		}
+		end := bytes.Index(data[len(delims[0]):], []byte(delims[1]))
+		if end < 0 {
+			return len(data)
+		}
+		return len(delims[0]) + end + len(delims[1])
+	}
+	return 0
+}
+
+func escapeAmpersands(data []byte) []byte {
+	offsets := unescapedAmpersands(data)
+	if len(offsets) == 0 {
+		return data
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, offset := range offsets {
+		buf.Write(data[last:offset])
+		buf.WriteString("&amp;")
+		last = offset + 1
+	}
+	buf.Write(data[last:])
+	return buf.Bytes()
+}
+
+type optionRange struct {
+	class      string
+	name       string
+	start, end int
+}
+
+// findDeprecatedOptions returns the byte ranges of deprecated options set on
+// an object of the deprecating class, or at the top level of a config that
+// contains such an object. data must be well-formed.
+func findDeprecatedOptions(data []byte) []optionRange {
+	var topLevel, nested []optionRange
+	classes := make(map[string]bool)
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var stack []string
+	for {
+		start := int(d.InputOffset())
+		t, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			class := attr(t, "class")
+			if class != "" {
+				classes[class] = true
+			}
+			if t.Name.Local == optionElement {
+				name := attr(t, "name")
+				if err := d.Skip(); err != nil {
+					return nil
+				}
+				o := optionRange{name: name, start: start, end: int(d.InputOffset())}
+				switch len(stack) {
+				case 1:
+					topLevel = append(topLevel, o)
+				case 2:
+					o.class = stack[1]
+					nested = append(nested, o)
+				}
+				continue
+			}
+			stack = append(stack, class)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	var ranges []optionRange
+	for _, o := range nested {
+		if isDeprecated(o.class, o.name) {
+			ranges = append(ranges, o)
+		}
+	}
+	for _, o := range topLevel {
+		for _, dep := range deprecatedOptions {
+			if classes[dep.class] && dep.name == o.name {
+				o.class = dep.class
+				ranges = append(ranges, o)
+			}
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	return ranges
+}
+
+func isDeprecated(class, name string) bool {
+	for _, dep := range deprecatedOptions {
+		if dep.class == class && dep.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// expandToLine grows [start, end) to cover whole lines when the range is
+// the only content on them, so removing it leaves no blank line behind.
+func expandToLine(data []byte, start, end int) (int, int) {
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	if len(bytes.TrimSpace(data[lineStart:start])) != 0 {
+		return start, end
+	}
+	lineEnd := len(data)
+	if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if len(bytes.TrimSpace(data[end:lineEnd])) != 0 {
+		return start, end
+	}
+	return lineStart, lineEnd
+}
+
+func lineAt(data []byte, offset int) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+var syntaxErrorLinePattern = regexp.MustCompile(`line (\d+)`)
+
+func syntaxErrorLine(err error) int {
+	if e, ok := err.(*xml.SyntaxError); ok {
+		return e.Line
+	}
+	var line int
+	if m := syntaxErrorLinePattern.FindStringSubmatch(err.Error()); m != nil {
+		fmt.Sscan(m[1], &line)
+	}
+	if line == 0 {
+		line = 1
+	}
+	return line
+}
+
+// collectFiles expands directories into the configs they contain.
+func collectFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if d.Name() == "AndroidTest.xml" ||
+				(strings.HasSuffix(p, ".xml") && filepath.Base(filepath.Dir(p)) == "config") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func main() {
+	fixFlag := flag.Bool("fix", false, "rewrite files to fix fixable findings")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-fix] <file or dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := collectFiles(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	remaining := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		findings := lint(path, data)
+		if *fixFlag && hasFixable(findings) {
+			fixed, err := fix(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: cannot fix: %v\n", path, err)
+			} else if err := os.WriteFile(path, fixed, 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			} else {
+				findings = lint(path, fixed)
+			}
+		}
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		remaining += len(findings)
+	}
+	if remaining > 0 {
+		os.Exit(1)
+	}
+}
+
+func hasFixable(findings []finding) bool {
+	for _, f := range findings {
+		if f.fixable {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const validModuleConfig = `<?xml version="1.0" encoding="utf-8"?>
+<!-- Copyright & license -->
+<configuration description="Tests the compatibility of apps">
+    <option name="config-descriptor:metadata" key="plan" value="csuite-launch"/>
+    <option name="package-name" value="com.example.app"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest"/>
+</configuration>
+`
+
+func TestLint_validConfig_returnsNoFindings(t *testing.T) {
+	findings := lint("AndroidTest.xml", []byte(validModuleConfig))
+
+	if len(findings) != 0 {
+		t.Errorf("got findings %v, want none", findings)
+	}
+}
+
+func TestLint_unescapedAmpersand_reportsFixableFinding(t *testing.T) {
+	config := `<configuration>
+    <option name="test-label" value="Launch &amp; crawl" />
+    <option name="test-tag" value="a & b" />
+</configuration>`
+
+	findings := lint("plan.xml", []byte(config))
+
+	assertRules(t, findings, "escaping")
+	if findings[0].line != 3 || !findings[0].fixable {
+		t.Errorf("got %v, want fixable finding on line 3", findings[0])
+	}
+}
+
+func TestLint_malformedXml_reportsLine(t *testing.T) {
+	config := "<configuration>\n<option name=\"a\" value=\"b\">\n</configuration>\n"
+
+	findings := lint("plan.xml", []byte(config))
+
+	assertRules(t, findings, "well-formed")
+	if findings[0].line != 3 {
+		t.Errorf("got line %d, want 3", findings[0].line)
+	}
+}
+
+func TestLint_deprecatedOptions_reportsNestedAndTopLevelOptions(t *testing.T) {
+	config := `<configuration>
+    <option name="config-descriptor:metadata" key="plan" value="csuite-launch"/>
+    <option name="package-name" value="com.example.app"/>
+    <option name="retry-count" value="3"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest">
+        <option name="retry-count" value="3"/>
+    </test>
+</configuration>`
+
+	findings := lint("AndroidTest.xml", []byte(config))
+
+	assertRules(t, findings, "deprecated-option", "deprecated-option")
+}
+
+func TestLint_retryCountOnOtherClass_isNotDeprecated(t *testing.T) {
+	config := `<configuration>
+    <target_preparer class="com.example.Preparer">
+        <option name="retry-count" value="3"/>
+    </target_preparer>
+</configuration>`
+
+	findings := lint("AndroidTest.xml", []byte(config))
+
+	assertRules(t, findings)
+}
+
+func TestLint_launchTestWithoutPackageOrMetadata_reportsFindings(t *testing.T) {
+	config := `<configuration>
+    <test class="com.android.compatibility.testtype.AppLaunchTest"/>
+</configuration>`
+
+	findings := lint("AndroidTest.xml", []byte(config))
+
+	assertRules(t, findings, "package-name", "plan-metadata")
+}
+
+func TestLint_hostTestWithoutMetadata_returnsNoFindings(t *testing.T) {
+	config := `<configuration>
+    <test class="com.android.tradefed.testtype.HostTest">
+        <option name="class" value="com.android.compatibility.CSuiteUnitTests" />
+    </test>
+</configuration>`
+
+	findings := lint("AndroidTest.xml", []byte(config))
+
+	assertRules(t, findings)
+}
+
+func TestFix_escapesAndRemovesDeprecatedOptions(t *testing.T) {
+	config := `<configuration>
+    <option name="config-descriptor:metadata" key="plan" value="a & b"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest">
+        <option name="retry-count" value="3"/>
+        <option name="app-launch-timeout-ms" value="5000"/>
+    </test>
+</configuration>`
+
+	got, err := fix([]byte(config))
+
+	if err != nil {
+		t.Fatalf("fix() failed: %v", err)
+	}
+	want := `<configuration>
+    <option name="config-descriptor:metadata" key="plan" value="a &amp; b"/>
+    <test class="com.android.compatibility.testtype.AppLaunchTest">
+        <option name="app-launch-timeout-ms" value="5000"/>
+    </test>
+</configuration>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFix_malformedXml_returnsError(t *testing.T) {
+	_, err := fix([]byte(`<configuration><option></configuration>`))
+
+	if err == nil {
+		t.Error("fix() succeeded, want error")
+	}
+}
+
+func TestCollectFiles_findsModuleConfigsAndPlans(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{
+		"csuite_com.example/AndroidTest.xml",
+		"csuite_com.example/Android.bp",
+		"res/config/launch.xml",
+		"src/main/AndroidManifest.xml",
+	} {
+		writeFile(t, filepath.Join(dir, p))
+	}
+
+	got, err := collectFiles([]string{dir})
+
+	if err != nil {
+		t.Fatalf("collectFiles() failed: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "csuite_com.example/AndroidTest.xml"),
+		filepath.Join(dir, "res/config/launch.xml"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func assertRules(t *testing.T, findings []finding, rules ...string) {
+	t.Helper()
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.rule)
+	}
+	if !reflect.DeepEqual(got, rules) {
+		t.Fatalf("got findings %v, want rules %v", findings, rules)
+	}
+}
+
+func writeFile(t *testing.T, p string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// top_apps pulls a ranked app list and generates the include filters that
+// select the top apps of some categories and countries, so that plans such
+// as "top 100 social apps" can be refreshed with one command.
+//
+// Usage:
+//
+//	top_apps -source <url or file> [-category <name>]... [-country <code>]... [-top <n>] [-format xml|list]
+//
+// The source is a JSON array of objects or a CSV file with a header row,
+// read over HTTP(S) or from disk. Each app has a package name (package,
+// package_name, packageName or app_package), a rank, and optionally a
+// category and a country. Category and country filters are case-insensitive;
+// an app matches when it matches any of the given values of each filter.
+//
+// The xml format is a Tradefed config with one include filter per app,
+// meant to be <include>d by a plan. The list format is a package list file
+// for generate_module.py.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/flagutil"
+	"android/test/app_compat/csuite/tools/internal/packagelist"
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+const (
+	generator           = "test/app_compat/csuite/tools/top_apps"
+	includeFilterOption = "compatibility:include-filter"
+)
+
+type app struct {
+	Package  string
+	Rank     int
+	Category string
+	Country  string
+}
+
+func fetch(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	resp, err := http.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s failed: %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseApps reads apps from a JSON array of objects or, failing that, from
+// CSV with a header row.
+func parseApps(data []byte) ([]app, error) {
+	var records []packagelist.Record
+	var err error
+	if json.Valid(data) {
+		records, err = packagelist.ReadJSONCatalog("source", bytes.NewReader(data), "")
+	} else {
+		records, err = packagelist.ReadCSVCatalog("source", bytes.NewReader(data), "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []app
+	for _, r := range records {
+		a := app{Package: r.Package, Category: r.Fields["category"], Country: r.Fields["country"]}
+		if !packagelist.ValidPackageName(a.Package) {
+			return nil, fmt.Errorf("%s: invalid package name %q", r.Location, a.Package)
+		}
+		rank, err := strconv.Atoi(r.Fields["rank"])
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): invalid rank %q", r.Location, a.Package, r.Fields["rank"])
+		}
+		a.Rank = rank
+		apps = append(apps, a)
+	}
+	return apps, nil
+}
+
+// selectTop returns the top apps by rank among those matching the filters,
+// without duplicate packages. top <= 0 means no limit.
+func selectTop(apps []app, categories, countries []string, top int) []app {
+	var selected []app
+	for _, a := range apps {
+		if matches(a.Category, categories) && matches(a.Country, countries) {
+			selected = append(selected, a)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Rank < selected[j].Rank })
+
+	seen := make(map[string]bool)
+	var deduped []app
+	for _, a := range selected {
+		if seen[a.Package] {
+			continue
+		}
+		seen[a.Package] = true
+		deduped = append(deduped, a)
+		if top > 0 && len(deduped) == top {
+			break
+		}
+	}
+	return deduped
+}
+
+func matches(value string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if strings.EqualFold(value, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func description(categories, countries []string, top int) string {
+	d := "Top"
+	if top > 0 {
+		d += " " + strconv.Itoa(top)
+	}
+	if len(categories) > 0 {
+		d += " " + strings.Join(categories, "/")
+	}
+	d += " apps"
+	if len(countries) > 0 {
+		d += " in " + strings.Join(countries, "/")
+	}
+	return d
+}
+
+func includeFilters(apps []app, desc string) *tfconfig.Configuration {
+	c := &tfconfig.Configuration{Description: desc}
+	for _, a := range apps {
+		c.Options = append(c.Options, tfconfig.Option{
+			Name:  includeFilterOption,
+			Value: packagelist.ModuleName(a.Package),
+		})
+	}
+	return c
+}
+
+func main() {
+	var categories, countries flagutil.StringList
+	source := flag.String("source", "", "URL or file of the ranked app list")
+	flag.Var(&categories, "category", "only include apps of this category; may be repeated")
+	flag.Var(&countries, "country", "only include apps ranked in this country; may be repeated")
+	top := flag.Int("top", 100, "number of apps to include; 0 for all")
+	format := flag.String("format", "xml", "output format: xml or list")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s -source <url or file> [-category <name>]... [-country <code>]... [-top <n>] [-format xml|list]\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *source == "" || flag.NArg() != 0 || (*format != "xml" && *format != "list") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := fetch(*source)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	apps, err := parseApps(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *source, err)
+		os.Exit(1)
+	}
+	selected := selectTop(apps, categories, countries, *top)
+
+	switch *format {
+	case "xml":
+		desc := description(categories, countries, *top)
+		os.Stdout.Write(tfconfig.MarshalGenerated(includeFilters(selected, desc), generator))
+	case "list":
+		var names []string
+		for _, a := range selected {
+			names = append(names, a.Package)
+		}
+		packagelist.Write(os.Stdout, names)
+	}
+}
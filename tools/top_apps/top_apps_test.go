@@ -0,0 +1,134 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"android/test/app_compat/csuite/tools/internal/tfconfig"
+)
+
+func TestParseApps_json(t *testing.T) {
+	data := `[{"packageName": "com.example.a", "rank": 2, "category": "Social", "country": "US"},
+		{"package": "com.example.b", "rank": "1"}]`
+
+	got, err := parseApps([]byte(data))
+
+	if err != nil {
+		t.Fatalf("parseApps() failed: %v", err)
+	}
+	want := []app{
+		{Package: "com.example.a", Rank: 2, Category: "Social", Country: "US"},
+		{Package: "com.example.b", Rank: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseApps_csv(t *testing.T) {
+	data := "rank,package_name,category,country\n1,com.example.a,Games,JP\n"
+
+	got, err := parseApps([]byte(data))
+
+	if err != nil {
+		t.Fatalf("parseApps() failed: %v", err)
+	}
+	if want := []app{{Package: "com.example.a", Rank: 1, Category: "Games", Country: "JP"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseApps_invalidEntries_returnError(t *testing.T) {
+	for _, data := range []string{
+		`[{"package": "not a package", "rank": 1}]`,
+		`[{"package": "com.example.a"}]`,
+		"rank,package\nfirst,com.example.a\n",
+	} {
+		if _, err := parseApps([]byte(data)); err == nil {
+			t.Errorf("parseApps(%q) succeeded, want error", data)
+		}
+	}
+}
+
+func TestSelectTop_filtersSortsAndLimits(t *testing.T) {
+	apps := []app{
+		{Package: "com.example.games", Rank: 1, Category: "Games", Country: "US"},
+		{Package: "com.example.social3", Rank: 3, Category: "Social", Country: "US"},
+		{Package: "com.example.social2", Rank: 2, Category: "Social", Country: "US"},
+		{Package: "com.example.social2", Rank: 5, Category: "Social", Country: "US"},
+		{Package: "com.example.social4", Rank: 4, Category: "social", Country: "us"},
+		{Package: "com.example.socialjp", Rank: 1, Category: "Social", Country: "JP"},
+	}
+
+	got := selectTop(apps, []string{"social"}, []string{"US"}, 2)
+
+	if names := packages(got); !reflect.DeepEqual(names, []string{"com.example.social2", "com.example.social3"}) {
+		t.Errorf("got %v", names)
+	}
+}
+
+func TestSelectTop_noFiltersOrLimit_returnsAllByRank(t *testing.T) {
+	apps := []app{{Package: "com.example.b", Rank: 2}, {Package: "com.example.a", Rank: 1}}
+
+	got := selectTop(apps, nil, nil, 0)
+
+	if names := packages(got); !reflect.DeepEqual(names, []string{"com.example.a", "com.example.b"}) {
+		t.Errorf("got %v", names)
+	}
+}
+
+func TestIncludeFilters_usesGeneratedModuleNames(t *testing.T) {
+	c := includeFilters([]app{{Package: "com.example.a"}}, "Top 1 apps")
+
+	want := []tfconfig.Option{{Name: "compatibility:include-filter", Value: "csuite_com.example.a"}}
+	if !reflect.DeepEqual(c.Options, want) || c.Description != "Top 1 apps" {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestDescription(t *testing.T) {
+	if got := description([]string{"Social"}, []string{"US", "CA"}, 100); got != "Top 100 Social apps in US/CA" {
+		t.Errorf("got %q", got)
+	}
+	if got := description(nil, nil, 0); got != "Top apps" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFetch_http(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	data, err := fetch(server.URL)
+
+	if err != nil || string(data) != "[]" {
+		t.Errorf("fetch() = %q, %v", data, err)
+	}
+}
+
+func packages(apps []app) []string {
+	var names []string
+	for _, a := range apps {
+		names = append(names, a.Package)
+	}
+	return names
+}
@@ -0,0 +1,313 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// ui_diff compares the screenshots and UI dumps collected by crawler runs
+// on two builds and reports the apps whose screens changed by more than a
+// threshold, to help find UI regressions.
+//
+// Usage:
+//
+//	ui_diff [-threshold <fraction>] [-json] <old artifact dir> <new artifact dir>
+//
+// Each artifact directory holds one directory per package, containing .png
+// screenshots and .xml UI dumps in the uiautomator format. Files are
+// compared by their path relative to the artifact directory. A screenshot's
+// difference is the fraction of pixels that changed; screenshots of
+// different sizes differ entirely. A UI dump's difference is the fraction of
+// views, identified by their class and resource ID and those of their
+// ancestors, that were added or removed. Files missing from either build
+// are always reported. ui_diff exits with status 1 when anything is
+// reported.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/test/app_compat/csuite/tools/internal/suitediff"
+)
+
+// pixelTolerance is the largest per-channel change, out of 0xffff, that is
+// not counted as a difference, to ignore compression and dithering noise.
+const pixelTolerance = 0x1000
+
+// maxViews is the number of added and removed views listed per UI dump.
+const maxViews = 5
+
+type diff struct {
+	Package string  `json:"package"`
+	File    string  `json:"file"`
+	Kind    string  `json:"kind"`
+	Ratio   float64 `json:"ratio"`
+	// Detail describes the difference, e.g. the views added or removed.
+	Detail string `json:"detail,omitempty"`
+}
+
+// listArtifacts returns the paths of the .png and .xml files under dir,
+// relative to dir and with forward slashes.
+func listArtifacts(dir string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(p); ext != ".png" && ext != ".xml" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return files, err
+}
+
+func decodePNG(p string) (image.Image, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	return img, nil
+}
+
+// compareImages returns the fraction of pixels that differ between a and b.
+func compareImages(a, b image.Image) float64 {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return 1
+	}
+	total := ab.Dx() * ab.Dy()
+	if total == 0 {
+		return 0
+	}
+	changed := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			if !similar(a.At(ab.Min.X+x, ab.Min.Y+y), b.At(bb.Min.X+x, bb.Min.Y+y)) {
+				changed++
+			}
+		}
+	}
+	return float64(changed) / float64(total)
+}
+
+func similar(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	for _, d := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		if d[0] > d[1]+pixelTolerance || d[1] > d[0]+pixelTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// uiNode is a node of a uiautomator dump.
+type uiNode struct {
+	Class      string   `xml:"class,attr"`
+	ResourceID string   `xml:"resource-id,attr"`
+	Nodes      []uiNode `xml:"node"`
+}
+
+// views returns the views of a uiautomator dump as paths of class and
+// resource ID from the root, one entry per view.
+func views(r io.Reader) ([]string, error) {
+	var root struct {
+		Nodes []uiNode `xml:"node"`
+	}
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, err
+	}
+	var paths []string
+	var walk func(prefix string, nodes []uiNode)
+	walk = func(prefix string, nodes []uiNode) {
+		for _, n := range nodes {
+			p := prefix + "/" + n.Class
+			if n.ResourceID != "" {
+				p += "#" + n.ResourceID
+			}
+			paths = append(paths, p)
+			walk(p, n.Nodes)
+		}
+	}
+	walk("", root.Nodes)
+	return paths, nil
+}
+
+// compareViews returns the fraction of views added or removed between a and
+// b, and the views added and removed.
+func compareViews(a, b []string) (ratio float64, added, removed []string) {
+	removed, added = suitediff.Difference(a, b), suitediff.Difference(b, a)
+	total := len(a)
+	if len(b) > total {
+		total = len(b)
+	}
+	if total == 0 {
+		return 0, nil, nil
+	}
+	return float64(len(added)+len(removed)) / float64(total), added, removed
+}
+
+func summarize(label string, views []string) string {
+	if len(views) == 0 {
+		return ""
+	}
+	s := label + " " + strings.Join(views[:min(len(views), maxViews)], ", ")
+	if len(views) > maxViews {
+		s += fmt.Sprintf(" and %d more", len(views)-maxViews)
+	}
+	return s
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func compareFile(oldDir, newDir, rel string) (diff, error) {
+	d := diff{Package: strings.SplitN(rel, "/", 2)[0], File: rel}
+	oldPath, newPath := filepath.Join(oldDir, rel), filepath.Join(newDir, rel)
+	if path.Ext(rel) == ".png" {
+		d.Kind = "screenshot"
+		a, err := decodePNG(oldPath)
+		if err != nil {
+			return d, err
+		}
+		b, err := decodePNG(newPath)
+		if err != nil {
+			return d, err
+		}
+		d.Ratio = compareImages(a, b)
+		return d, nil
+	}
+
+	d.Kind = "hierarchy"
+	var dumps [2][]string
+	for i, p := range []string{oldPath, newPath} {
+		f, err := os.Open(p)
+		if err != nil {
+			return d, err
+		}
+		dumps[i], err = views(f)
+		f.Close()
+		if err != nil {
+			return d, fmt.Errorf("%s: %v", p, err)
+		}
+	}
+	ratio, added, removed := compareViews(dumps[0], dumps[1])
+	d.Ratio = ratio
+	var details []string
+	for _, s := range []string{summarize("added", added), summarize("removed", removed)} {
+		if s != "" {
+			details = append(details, s)
+		}
+	}
+	d.Detail = strings.Join(details, "; ")
+	return d, nil
+}
+
+// compare returns the differences above threshold between the artifact
+// directories, and the files found in only one of them.
+func compare(oldDir, newDir string, threshold float64) ([]diff, error) {
+	oldFiles, err := listArtifacts(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := listArtifacts(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []diff
+	for rel := range oldFiles {
+		if !newFiles[rel] {
+			diffs = append(diffs, diff{Package: strings.SplitN(rel, "/", 2)[0], File: rel, Kind: "removed", Ratio: 1})
+			continue
+		}
+		d, err := compareFile(oldDir, newDir, rel)
+		if err != nil {
+			return nil, err
+		}
+		if d.Ratio > threshold {
+			diffs = append(diffs, d)
+		}
+	}
+	for rel := range newFiles {
+		if !oldFiles[rel] {
+			diffs = append(diffs, diff{Package: strings.SplitN(rel, "/", 2)[0], File: rel, Kind: "added", Ratio: 1})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].File < diffs[j].File })
+	return diffs, nil
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 0.01, "fraction of pixels or views that may change without being reported")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [-threshold <fraction>] [-json] <old artifact dir> <new artifact dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	diffs, err := compare(flag.Arg(0), flag.Arg(1), *threshold)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *asJSON {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if diffs == nil {
+			diffs = []diff{}
+		}
+		e.Encode(diffs)
+	} else {
+		for _, d := range diffs {
+			fmt.Printf("%s: %s %s, %.1f%% changed\n", d.Package, d.Kind, d.File, d.Ratio*100)
+			if d.Detail != "" {
+				fmt.Printf("    %s\n", d.Detail)
+			}
+		}
+	}
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2020 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dump = `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<hierarchy rotation="0">
+  <node class="android.widget.FrameLayout" resource-id="">
+    <node class="android.widget.TextView" resource-id="com.example.a:id/title" />
+    <node class="android.widget.Button" resource-id="com.example.a:id/ok" />
+  </node>
+</hierarchy>`
+
+func writePNG(t *testing.T, p string, w, h, changed int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		c := color.RGBA{0x80, 0x80, 0x80, 0xff}
+		if i < changed {
+			c = color.RGBA{0xff, 0, 0, 0xff}
+		}
+		img.Set(i%w, i/w, c)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestViews(t *testing.T) {
+	got, err := views(strings.NewReader(dump))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/android.widget.FrameLayout",
+		"/android.widget.FrameLayout/android.widget.TextView#com.example.a:id/title",
+		"/android.widget.FrameLayout/android.widget.Button#com.example.a:id/ok",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCompareViews(t *testing.T) {
+	ratio, added, removed := compareViews([]string{"/a", "/a/b", "/a/c"}, []string{"/a", "/a/b", "/a/b", "/a/d"})
+
+	if ratio != 0.75 {
+		t.Errorf("got ratio %v, want 0.75", ratio)
+	}
+	if !reflect.DeepEqual(added, []string{"/a/b", "/a/d"}) || !reflect.DeepEqual(removed, []string{"/a/c"}) {
+		t.Errorf("got added %q and removed %q", added, removed)
+	}
+}
+
+func TestCompareImagesDifferentSizes(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	b := image.NewRGBA(image.Rect(0, 0, 2, 3))
+
+	if got := compareImages(a, b); got != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+}
+
+func TestCompareImagesIgnoresNoise(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	b := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	a.Set(0, 0, color.RGBA{0x80, 0x80, 0x80, 0xff})
+	b.Set(0, 0, color.RGBA{0x82, 0x80, 0x80, 0xff})
+	b.Set(1, 0, color.RGBA{0xff, 0, 0, 0xff})
+
+	if got := compareImages(a, b); got != 0.5 {
+		t.Errorf("got %v, want 0.5", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	writePNG(t, filepath.Join(oldDir, "com.example.a", "main.png"), 10, 10, 0)
+	writePNG(t, filepath.Join(newDir, "com.example.a", "main.png"), 10, 10, 20)
+	writePNG(t, filepath.Join(oldDir, "com.example.b", "main.png"), 10, 10, 0)
+	writePNG(t, filepath.Join(newDir, "com.example.b", "main.png"), 10, 10, 1)
+	writeFile(t, filepath.Join(oldDir, "com.example.a", "main.xml"), dump)
+	writeFile(t, filepath.Join(newDir, "com.example.a", "main.xml"),
+		strings.Replace(dump, "id/ok", "id/cancel", 1))
+	writeFile(t, filepath.Join(oldDir, "com.example.c", "main.xml"), dump)
+	writeFile(t, filepath.Join(newDir, "com.example.d", "main.xml"), dump)
+	writeFile(t, filepath.Join(newDir, "com.example.d", "notes.txt"), "ignored")
+
+	got, err := compare(oldDir, newDir, 0.05)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []diff{
+		{Package: "com.example.a", File: "com.example.a/main.png", Kind: "screenshot", Ratio: 0.2},
+		{
+			Package: "com.example.a", File: "com.example.a/main.xml", Kind: "hierarchy", Ratio: 2.0 / 3,
+			Detail: "added /android.widget.FrameLayout/android.widget.Button#com.example.a:id/cancel; " +
+				"removed /android.widget.FrameLayout/android.widget.Button#com.example.a:id/ok",
+		},
+		{Package: "com.example.c", File: "com.example.c/main.xml", Kind: "removed", Ratio: 1},
+		{Package: "com.example.d", File: "com.example.d/main.xml", Kind: "added", Ratio: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}